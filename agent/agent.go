@@ -0,0 +1,358 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"nextunit/op2aws/history"
+	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	ACTION_GET    = "get"
+	ACTION_STATUS = "status"
+	ACTION_STOP   = "stop"
+
+	DEFAULT_REFRESH_WINDOW = 10 * time.Minute
+	DEFAULT_CHECK_INTERVAL = 30 * time.Second
+	DEFAULT_IDLE_TIMEOUT   = 8 * time.Hour
+	RETRY_INTERVAL         = 5 * time.Minute
+)
+
+// CredentialsProvider generates the credentials of a profile with their source. With force the cache is not used.
+type CredentialsProvider func(profile *opaws.OpProfile, force bool) (*sts.Credentials, string, error)
+
+// RefreshRecorder records the credentials, that the agent generates in the background without a request.
+type RefreshRecorder func(profile *opaws.OpProfile, credentials *sts.Credentials, source string)
+
+type Request struct {
+	Action  string
+	Profile *opaws.OpProfile `json:",omitempty"`
+	Force   bool             `json:",omitempty"`
+}
+
+// Response contains the credentials with their source.
+type Response struct {
+	Credentials *sts.Credentials `json:",omitempty"`
+	Source      string           `json:",omitempty"`
+	Status      *Status          `json:",omitempty"`
+	Error       string           `json:",omitempty"`
+	ErrorKind   string           `json:",omitempty"`
+}
+
+type Status struct {
+	StartedAt   time.Time
+	LastRequest time.Time
+	Profiles    []ProfileStatus
+}
+
+type ProfileStatus struct {
+	Profile     *opaws.OpProfile
+	Expiration  *time.Time `json:",omitempty"`
+	LastRefresh time.Time
+	LastError   string `json:",omitempty"`
+}
+
+type agentProfile struct {
+	mutex sync.Mutex
+
+	profile     *opaws.OpProfile
+	credentials *sts.Credentials
+	lastRefresh time.Time
+	lastError   string
+	refreshing  *refreshCall
+}
+
+// refreshCall is a running call of the provider, that other requests of the profile wait for.
+type refreshCall struct {
+	done        chan struct{}
+	credentials *sts.Credentials
+	err         error
+}
+
+type Agent struct {
+	provider      CredentialsProvider
+	recorder      RefreshRecorder
+	refreshWindow time.Duration
+	checkInterval time.Duration
+	idleTimeout   time.Duration
+
+	mutex       sync.Mutex
+	profiles    map[string]*agentProfile
+	startedAt   time.Time
+	lastRequest time.Time
+	listener    net.Listener
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func getProfileKey(profile *opaws.OpProfile) string {
+	return fmt.Sprintf(
+		"%s-%s-%s-%s-%s-%s-%s-%s-%s-%s",
+		profile.Vault, profile.Item, profile.MFA, profile.AssumeRole, profile.LabelAccessKey, profile.LabelSecretAccessKey, profile.SourceProfile,
+		profile.SessionName, profile.Region, profile.Duration,
+	)
+}
+
+func (p *agentProfile) expiresWithin(window time.Duration) bool {
+	return p.credentials == nil ||
+		p.credentials.Expiration == nil ||
+		time.Until(*p.credentials.Expiration) < window
+}
+
+func (a *Agent) getProfile(profile *opaws.OpProfile) *agentProfile {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key := getProfileKey(profile)
+	if _, ok := a.profiles[key]; !ok {
+		a.profiles[key] = &agentProfile{profile: profile}
+	}
+
+	return a.profiles[key]
+}
+
+// refresh returns the credentials of the profile with their source. The provider is called without the lock.
+func (a *Agent) refresh(p *agentProfile, force bool) (*sts.Credentials, string, error) {
+	p.mutex.Lock()
+	if !force && !p.expiresWithin(a.refreshWindow) {
+		credentials := p.credentials
+		p.mutex.Unlock()
+		return credentials, history.SOURCE_AGENT, nil
+	}
+
+	if call := p.refreshing; call != nil {
+		p.mutex.Unlock()
+		<-call.done
+		return call.credentials, history.SOURCE_AGENT, call.err
+	}
+
+	call := &refreshCall{done: make(chan struct{})}
+	p.refreshing = call
+	// Credentials, that expire soon, are generated again without the cache.
+	force = force || p.credentials != nil
+	p.mutex.Unlock()
+
+	credentials, source, err := a.provider(p.profile, force)
+
+	p.mutex.Lock()
+	p.refreshing = nil
+	p.lastRefresh = time.Now()
+	if err != nil {
+		p.lastError = redact.String(err.Error())
+	} else {
+		p.credentials = credentials
+		p.lastError = ""
+	}
+	p.mutex.Unlock()
+
+	call.credentials, call.err = credentials, err
+	close(call.done)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return credentials, source, nil
+}
+
+// Add keeps the credentials of the profile warm, without waiting for a request.
+func (a *Agent) Add(profile *opaws.OpProfile) {
+	a.getProfile(profile)
+}
+
+// GetCredentials returns the credentials of the profile with their source and keeps them warm from now on.
+func (a *Agent) GetCredentials(profile *opaws.OpProfile, force bool) (*sts.Credentials, string, error) {
+	return a.refresh(a.getProfile(profile), force)
+}
+
+func (a *Agent) getProfiles() []*agentProfile {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	profiles := []*agentProfile{}
+	for _, p := range a.profiles {
+		profiles = append(profiles, p)
+	}
+
+	return profiles
+}
+
+func (a *Agent) GetStatus() *Status {
+	a.mutex.Lock()
+	status := &Status{StartedAt: a.startedAt, LastRequest: a.lastRequest, Profiles: []ProfileStatus{}}
+	a.mutex.Unlock()
+
+	for _, p := range a.getProfiles() {
+		p.mutex.Lock()
+		profileStatus := ProfileStatus{Profile: p.profile, LastRefresh: p.lastRefresh, LastError: p.lastError}
+		if p.credentials != nil {
+			profileStatus.Expiration = p.credentials.Expiration
+		}
+		p.mutex.Unlock()
+
+		status.Profiles = append(status.Profiles, profileStatus)
+	}
+
+	sort.Slice(status.Profiles, func(i, j int) bool {
+		return getProfileKey(status.Profiles[i].Profile) < getProfileKey(status.Profiles[j].Profile)
+	})
+
+	return status
+}
+
+func (a *Agent) refreshAll() {
+	for _, p := range a.getProfiles() {
+		// A failed refresh is not retried right away.
+		p.mutex.Lock()
+		retry := p.refreshing == nil && (p.lastError == "" || time.Since(p.lastRefresh) > RETRY_INTERVAL)
+		p.mutex.Unlock()
+
+		if !retry {
+			continue
+		}
+
+		credentials, source, err := a.refresh(p, false)
+		if err == nil && source != history.SOURCE_AGENT && a.recorder != nil {
+			a.recorder(p.profile, credentials, source)
+		}
+	}
+}
+
+func (a *Agent) isIdle() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.idleTimeout > 0 && time.Since(a.lastRequest) > a.idleTimeout
+}
+
+func (a *Agent) maintain() {
+	ticker := time.NewTicker(a.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			if a.isIdle() {
+				a.Close()
+				return
+			}
+
+			a.refreshAll()
+		}
+	}
+}
+
+func (a *Agent) handleRequest(request *Request) *Response {
+	a.mutex.Lock()
+	a.lastRequest = time.Now()
+	a.mutex.Unlock()
+
+	switch request.Action {
+	case ACTION_GET:
+		if request.Profile == nil {
+			return &Response{Error: "The request contains no profile"}
+		}
+
+		credentials, source, err := a.GetCredentials(request.Profile, request.Force)
+		if err != nil {
+			return &Response{Error: redact.String(err.Error()), ErrorKind: getErrorKind(err)}
+		}
+
+		return &Response{Credentials: credentials, Source: source}
+	case ACTION_STATUS:
+		return &Response{Status: a.GetStatus()}
+	case ACTION_STOP:
+		go a.Close()
+		return &Response{}
+	default:
+		return &Response{Error: fmt.Sprintf("Unknown action %s", request.Action)}
+	}
+}
+
+func (a *Agent) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	request := &Request{}
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(request); err != nil {
+		json.NewEncoder(conn).Encode(&Response{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(conn).Encode(a.handleRequest(request))
+}
+
+// Serve accepts connections and refreshes the profiles until the agent is closed.
+func (a *Agent) Serve(listener net.Listener) error {
+	a.mutex.Lock()
+	a.listener = listener
+	a.startedAt = time.Now()
+	a.lastRequest = a.startedAt
+	a.mutex.Unlock()
+
+	go a.refreshAll()
+	go a.maintain()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-a.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		go a.handleConnection(conn)
+	}
+}
+
+func (a *Agent) Close() {
+	a.closeOnce.Do(func() {
+		close(a.done)
+
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		if a.listener != nil {
+			a.listener.Close()
+		}
+	})
+}
+
+func (a *Agent) RefreshWindow(refreshWindow time.Duration) {
+	a.refreshWindow = refreshWindow
+}
+
+func (a *Agent) CheckInterval(checkInterval time.Duration) {
+	a.checkInterval = checkInterval
+}
+
+func (a *Agent) IdleTimeout(idleTimeout time.Duration) {
+	a.idleTimeout = idleTimeout
+}
+
+// RecordRefreshes sets the recorder of the credentials, that are generated in the background.
+func (a *Agent) RecordRefreshes(recorder RefreshRecorder) {
+	a.recorder = recorder
+}
+
+func New(provider CredentialsProvider) *Agent {
+	return &Agent{
+		provider:      provider,
+		refreshWindow: DEFAULT_REFRESH_WINDOW,
+		checkInterval: DEFAULT_CHECK_INTERVAL,
+		idleTimeout:   DEFAULT_IDLE_TIMEOUT,
+		profiles:      map[string]*agentProfile{},
+		done:          make(chan struct{}),
+	}
+}
@@ -0,0 +1,291 @@
+package agent_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"nextunit/op2aws/agent"
+	"nextunit/op2aws/history"
+	"nextunit/op2aws/opaws"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	providerMutex sync.Mutex
+
+	providerReturnValue      *sts.Credentials
+	providerValidityReturn   time.Duration
+	providerErrorReturnValue error
+
+	providerCallCount int
+
+	// providerBlock lets the provider wait like for a prompt of 1password, until the channel is closed.
+	providerBlock chan struct{}
+
+	providerInput []bool
+
+	testProfile = &opaws.OpProfile{
+		Name:       "test-profile",
+		Vault:      "test-vault",
+		Item:       "test-item",
+		AssumeRole: "test-assume-role",
+	}
+)
+
+func setupTestCases() {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+
+	providerReturnValue = &sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+	}
+	providerValidityReturn = time.Hour
+	providerErrorReturnValue = fmt.Errorf("Test error")
+
+	providerCallCount = 0
+	providerBlock = nil
+
+	providerInput = []bool{}
+}
+
+func provider(profile *opaws.OpProfile, force bool) (*sts.Credentials, string, error) {
+	providerMutex.Lock()
+	block := providerBlock
+	providerMutex.Unlock()
+	if block != nil {
+		<-block
+	}
+
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+
+	providerCallCount++
+	providerInput = append(providerInput, force)
+
+	if providerReturnValue == nil {
+		return nil, "", providerErrorReturnValue
+	}
+
+	credentials := *providerReturnValue
+	credentials.Expiration = aws.Time(time.Now().Add(providerValidityReturn))
+	return &credentials, history.SOURCE_STS, nil
+}
+
+func getProviderCallCount() int {
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+
+	return providerCallCount
+}
+
+func startAgent(t *testing.T, a *agent.Agent) (*agent.Client, chan error) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(listener)
+	}()
+
+	return agent.NewClient(socketPath), served
+}
+
+func TestGetCredentials(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	a := agent.New(provider)
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	credentials, source, err := client.GetCredentials(testProfile, false)
+	assert.Nil(err)
+	assert.Equal("access-key-id", *credentials.AccessKeyId)
+	assert.Equal("session-token", *credentials.SessionToken)
+	assert.Equal(history.SOURCE_STS, source, "The source of the provider should be returned")
+
+	credentials, source, err = client.GetCredentials(testProfile, false)
+	assert.Nil(err)
+	assert.Equal("access-key-id", *credentials.AccessKeyId)
+	assert.Equal(history.SOURCE_AGENT, source)
+	assert.Equal(1, getProviderCallCount(), "The agent should keep the credentials")
+
+	_, _, err = client.GetCredentials(testProfile, true)
+	assert.Nil(err)
+	assert.Equal([]bool{false, true}, providerInput)
+}
+
+func TestGetCredentialsError(t *testing.T) {
+	setupTestCases()
+	providerReturnValue = nil
+
+	a := agent.New(provider)
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	credentials, _, err := client.GetCredentials(testProfile, false)
+	assert.ErrorContains(t, err, "Test error")
+	assert.False(t, errors.Is(err, agent.ErrAgentUnavailable))
+	assert.Nil(t, credentials)
+
+	status, err := client.GetStatus()
+	assert.Nil(t, err)
+	assert.Equal(t, "Test error", status.Profiles[0].LastError)
+}
+
+func TestGetCredentialsErrorKind(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	providerReturnValue = nil
+	providerErrorReturnValue = &opaws.StageError{Stage: opaws.STAGE_ROLE_TRUST, Kind: opaws.ErrRoleTrust, Err: fmt.Errorf("Test error")}
+
+	a := agent.New(provider)
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	_, _, err := client.GetCredentials(testProfile, false)
+	assert.ErrorContains(err, "Test error")
+	assert.ErrorIs(err, opaws.ErrRoleTrust, "The kind of the error should survive the socket")
+	assert.ErrorIs(err, opaws.ErrAccessDenied)
+
+	providerErrorReturnValue = fmt.Errorf("Test error")
+	_, _, err = client.GetCredentials(testProfile, true)
+	assert.NotErrorIs(err, opaws.ErrAccessDenied)
+}
+
+func TestAgentUnavailable(t *testing.T) {
+	client := agent.NewClient(filepath.Join(t.TempDir(), "agent.sock"))
+
+	_, _, err := client.GetCredentials(testProfile, false)
+	assert.ErrorIs(t, err, agent.ErrAgentUnavailable)
+
+	_, err = client.GetStatus()
+	assert.ErrorIs(t, err, agent.ErrAgentUnavailable)
+}
+
+func TestStatus(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	a := agent.New(provider)
+	a.Add(testProfile)
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	assert.Eventually(func() bool {
+		return getProviderCallCount() == 1
+	}, time.Second, 10*time.Millisecond, "Added profiles should be refreshed right away")
+
+	status, err := client.GetStatus()
+	assert.Nil(err)
+	assert.Len(status.Profiles, 1)
+	assert.Equal(testProfile, status.Profiles[0].Profile)
+	assert.NotNil(status.Profiles[0].Expiration)
+	assert.Empty(status.Profiles[0].LastError)
+	assert.False(status.StartedAt.IsZero())
+}
+
+func TestRefreshBeforeExpiration(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	providerValidityReturn = time.Minute
+
+	recordedSources := []string{}
+	a := agent.New(provider)
+	a.RefreshWindow(5 * time.Minute)
+	a.CheckInterval(10 * time.Millisecond)
+	a.RecordRefreshes(func(profile *opaws.OpProfile, credentials *sts.Credentials, source string) {
+		providerMutex.Lock()
+		defer providerMutex.Unlock()
+		recordedSources = append(recordedSources, source)
+	})
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	_, _, err := client.GetCredentials(testProfile, false)
+	assert.Nil(err)
+
+	assert.Eventually(func() bool {
+		return getProviderCallCount() >= 3
+	}, time.Second, 10*time.Millisecond, "Expiring credentials should be refreshed in the background")
+
+	providerMutex.Lock()
+	assert.Equal(true, providerInput[1], "Expiring credentials should be refreshed without the cache")
+	assert.NotEmpty(recordedSources, "The background refreshes should be recorded")
+	assert.NotContains(recordedSources, history.SOURCE_AGENT)
+	providerMutex.Unlock()
+}
+
+func TestStop(t *testing.T) {
+	setupTestCases()
+
+	a := agent.New(provider)
+	client, served := startAgent(t, a)
+
+	assert.Nil(t, client.Stop())
+
+	select {
+	case err := <-served:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "The agent should stop")
+	}
+
+	_, err := client.GetStatus()
+	assert.ErrorIs(t, err, agent.ErrAgentUnavailable)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	setupTestCases()
+
+	a := agent.New(provider)
+	a.IdleTimeout(50 * time.Millisecond)
+	a.CheckInterval(10 * time.Millisecond)
+	_, served := startAgent(t, a)
+
+	select {
+	case err := <-served:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "The agent should stop, when it is idle")
+		a.Close()
+	}
+}
+
+func TestStatusWhileProviderBlocks(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	providerBlock = make(chan struct{})
+
+	a := agent.New(provider)
+	client, _ := startAgent(t, a)
+	defer a.Close()
+
+	results := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, source, _ := client.GetCredentials(testProfile, false)
+			results <- source
+		}()
+	}
+
+	assert.Eventually(func() bool {
+		status, err := client.GetStatus()
+		return err == nil && len(status.Profiles) == 1
+	}, time.Second, 10*time.Millisecond, "The status should not wait for the provider")
+
+	close(providerBlock)
+	sources := []string{<-results, <-results}
+	assert.ElementsMatch([]string{history.SOURCE_STS, history.SOURCE_AGENT}, sources, "The second request should wait for the running refresh")
+	assert.Equal(1, getProviderCallCount())
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	DEFAULT_DIAL_TIMEOUT    = time.Second
+	DEFAULT_REQUEST_TIMEOUT = 5 * time.Minute
+)
+
+var ErrAgentUnavailable = errors.New("The agent is not running")
+
+type Client struct {
+	socketPath     string
+	requestTimeout time.Duration
+}
+
+func (c Client) send(request *Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, DEFAULT_DIAL_TIMEOUT)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentUnavailable, err.Error())
+	}
+	defer conn.Close()
+
+	// Generating new credentials can wait for 1password.
+	conn.SetDeadline(time.Now().Add(c.requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, err
+	}
+
+	response := &Response{}
+	if err := json.NewDecoder(conn).Decode(response); err != nil {
+		return nil, err
+	}
+
+	if response.Error != "" {
+		return nil, newRemoteError(response.Error, response.ErrorKind)
+	}
+
+	return response, nil
+}
+
+// GetCredentials returns the credentials of the profile from the agent with their source.
+func (c Client) GetCredentials(profile *opaws.OpProfile, force bool) (*sts.Credentials, string, error) {
+	response, err := c.send(&Request{Action: ACTION_GET, Profile: profile, Force: force})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if response.Credentials != nil {
+		redact.Add(aws.StringValue(response.Credentials.SecretAccessKey), aws.StringValue(response.Credentials.SessionToken))
+	}
+	return response.Credentials, response.Source, nil
+}
+
+func (c Client) GetStatus() (*Status, error) {
+	response, err := c.send(&Request{Action: ACTION_STATUS})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Status, nil
+}
+
+func (c Client) Stop() error {
+	_, err := c.send(&Request{Action: ACTION_STOP})
+	return err
+}
+
+func (c *Client) RequestTimeout(requestTimeout time.Duration) {
+	c.requestTimeout = requestTimeout
+}
+
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath, requestTimeout: DEFAULT_REQUEST_TIMEOUT}
+}
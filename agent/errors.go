@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"errors"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/cache"
+	"nextunit/op2aws/opaws"
+)
+
+// ERROR_KINDS names the sentinel errors, that are sent over the socket. The more specific kinds come first.
+var ERROR_KINDS = []struct {
+	Name string
+	Err  error
+}{
+	{"vault_unavailable", awsvault.ErrVaultUnavailable},
+	{"prompt_cancelled", awsvault.ErrPromptCancelled},
+	{"not_found", awsvault.ErrNotFound},
+	{"mfa_rejected", opaws.ErrMFARejected},
+	{"role_trust", opaws.ErrRoleTrust},
+	{"access_denied", opaws.ErrAccessDenied},
+	{"network", opaws.ErrNetwork},
+	{"config_write", opaws.ErrConfigWrite},
+	{"cache", cache.ErrCache},
+}
+
+// remoteError is an error of the agent with the sentinel error of its kind.
+type remoteError struct {
+	message string
+	kind    error
+}
+
+func (e *remoteError) Error() string {
+	return e.message
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.kind
+}
+
+func getErrorKind(err error) string {
+	for _, kind := range ERROR_KINDS {
+		if errors.Is(err, kind.Err) {
+			return kind.Name
+		}
+	}
+
+	return ""
+}
+
+func newRemoteError(message, kind string) error {
+	for _, k := range ERROR_KINDS {
+		if k.Name == kind {
+			return &remoteError{message: message, kind: k.Err}
+		}
+	}
+
+	return errors.New(message)
+}
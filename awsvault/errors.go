@@ -0,0 +1,72 @@
+package awsvault
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrVaultUnavailable = errors.New("1password is not available")
+	ErrPromptCancelled  = errors.New("The authorization prompt of 1password has been dismissed")
+	ErrNotFound         = errors.New("The vault, item or field has not been found inside of 1password")
+)
+
+// The messages of the 1password CLI in lower case.
+var (
+	PROMPT_CANCELLED_MESSAGES = []string{"authorization prompt dismissed", "authorization denied", "authorization timeout"}
+	NOT_FOUND_MESSAGES        = []string{"isn't an item", "isn't a vault", "isn't a field", "doesn't have a field", "does not have a field", "could not find", "no item found", "not found"}
+	UNAVAILABLE_MESSAGES      = []string{"not currently signed in", "account is not signed in", "no accounts configured", "cannot connect to 1password", "connecting to desktop app"}
+)
+
+// OpError is a failed call of the 1password CLI. Kind is one of the sentinel errors.
+type OpError struct {
+	Kind   error
+	Stderr string
+	Err    error
+}
+
+func (e *OpError) Error() string {
+	if e.Stderr != "" {
+		return e.Stderr
+	}
+
+	return e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+func (e *OpError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func containsAny(text string, messages []string) bool {
+	for _, message := range messages {
+		if strings.Contains(text, message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newOpError classifies the failed call by the message of the CLI.
+func newOpError(err error, stderr string) error {
+	opError := &OpError{Stderr: stderr, Err: err}
+	message := strings.ToLower(stderr)
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		opError.Kind = ErrVaultUnavailable
+	case containsAny(message, PROMPT_CANCELLED_MESSAGES):
+		opError.Kind = ErrPromptCancelled
+	case containsAny(message, NOT_FOUND_MESSAGES):
+		opError.Kind = ErrNotFound
+	case containsAny(message, UNAVAILABLE_MESSAGES):
+		opError.Kind = ErrVaultUnavailable
+	}
+
+	return opError
+}
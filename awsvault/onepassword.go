@@ -3,6 +3,9 @@ package awsvault
 import (
 	"encoding/json"
 	"fmt"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/redact"
+	"os/exec"
 	"strings"
 	"time"
 )
@@ -13,6 +16,14 @@ const (
 	AWS_ACCESS_KEY_FIELD_DEFAULT        = "aws_access_key_id"
 	AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT = "aws_secret_access_key"
 	AWS_MFA_FIELD_DEFAULT               = "TODO"
+	ITEM_CATEGORY                       = "API Credential"
+	FIELD_TYPE_TEXT                     = "text"
+	FIELD_TYPE_PASSWORD                 = "password"
+	FIELD_TYPE_OTP                      = "otp"
+	OTP_FIELD_LABEL                     = "one-time password"
+	TEMPLATE_TYPE_STRING                = "STRING"
+	TEMPLATE_TYPE_CONCEALED             = "CONCEALED"
+	TEMPLATE_TYPE_OTP                   = "OTP"
 )
 
 type OpInterface interface {
@@ -55,6 +66,13 @@ type OpItem struct {
 	Urls                  []OpUrl   `json:"urls"`
 }
 
+// OpField is a field of a new item.
+type OpField struct {
+	Label string
+	Type  string
+	Value string
+}
+
 type OnePassword struct {
 	commandLineClient CommandInterface
 
@@ -90,14 +108,99 @@ func getOutput(cmd CmdInterface) (string, error) {
 	return strings.TrimSpace(string(stdout)), nil
 }
 
+// runCommand runs op and logs the call with its timing. Only the first arguments are logged.
+func runCommand(commandLineClient CommandInterface, args ...string) (string, error) {
+	return run(commandLineClient.Command(CLI_COMMAND, args...), args)
+}
+
+// runCommandWithStdin runs op with the input on stdin.
+func runCommandWithStdin(commandLineClient CommandInterface, stdin []byte, args ...string) (string, error) {
+	return run(commandLineClient.CommandWithStdin(stdin, CLI_COMMAND, args...), args)
+}
+
+func run(cmd CmdInterface, args []string) (string, error) {
+	command := CLI_COMMAND
+	for i := 0; i < len(args) && i < 2; i++ {
+		command += " " + args[i]
+	}
+	start := time.Now()
+
+	output, err := getOutput(cmd)
+	if err != nil {
+		stderr := ""
+		if exitError, ok := err.(*exec.ExitError); ok {
+			stderr = strings.TrimSpace(string(exitError.Stderr))
+		}
+
+		logger.Debug("1password call failed", "command", command, "duration", logger.Since(start), "error", err, "stderr", stderr)
+		return "", newOpError(err, stderr)
+	}
+
+	logger.Debug("1password call", "command", command, "duration", logger.Since(start))
+	return output, nil
+}
+
 func (client *OnePassword) getItem(path string) (string, error) {
-	cmd := client.commandLineClient.Command(CLI_COMMAND, "read", path)
-	return getOutput(cmd)
+	return runCommand(client.commandLineClient, "read", path)
+}
+
+// editFields changes the fields of the item with a template on stdin of `op item edit`, which needs op 2.23.0.
+func (client *OnePassword) editFields(edit func(fields []map[string]any) []map[string]any) error {
+	output, err := runCommand(client.commandLineClient, "item", "get", client.item, "--vault", client.vault, "--format", "json")
+	if err != nil {
+		return err
+	}
+
+	var item map[string]any
+	if err := json.Unmarshal([]byte(output), &item); err != nil {
+		return err
+	}
+
+	fields := []map[string]any{}
+	if list, ok := item["fields"].([]any); ok {
+		for _, field := range list {
+			if f, ok := field.(map[string]any); ok {
+				fields = append(fields, f)
+			}
+		}
+	}
+	item["fields"] = edit(fields)
+
+	template, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = runCommandWithStdin(client.commandLineClient, template, "item", "edit", client.item, "--vault", client.vault)
+	return err
+}
+
+// getTemplateType returns the type of a field inside of an item template.
+func getTemplateType(fieldType string) string {
+	switch fieldType {
+	case FIELD_TYPE_PASSWORD:
+		return TEMPLATE_TYPE_CONCEALED
+	case FIELD_TYPE_OTP:
+		return TEMPLATE_TYPE_OTP
+	default:
+		return TEMPLATE_TYPE_STRING
+	}
+}
+
+// setField changes the value of the field with the label or adds a new field, when the item has none.
+func setField(fields []map[string]any, label, fieldType, value string) []map[string]any {
+	for _, field := range fields {
+		if field["label"] == label {
+			field["value"] = value
+			return fields
+		}
+	}
+
+	return append(fields, map[string]any{"type": getTemplateType(fieldType), "label": label, "value": value})
 }
 
 func GetVaults(commandLineClient CommandInterface) ([]OpVault, error) {
-	cmd := commandLineClient.Command(CLI_COMMAND, "vault", "list", "--format", "json")
-	output, err := getOutput(cmd)
+	output, err := runCommand(commandLineClient, "vault", "list", "--format", "json")
 	if err != nil {
 		return nil, err
 	}
@@ -112,8 +215,7 @@ func GetVaults(commandLineClient CommandInterface) ([]OpVault, error) {
 }
 
 func GetEntries(commandLineClient CommandInterface, vault, item string) ([]OpEntry, error) {
-	cmd := commandLineClient.Command(CLI_COMMAND, "item", "get", item, "--vault", vault, "--format", "json")
-	output, err := getOutput(cmd)
+	output, err := runCommand(commandLineClient, "item", "get", item, "--vault", vault, "--format", "json")
 	if err != nil {
 		return nil, err
 	}
@@ -129,9 +231,37 @@ func GetEntries(commandLineClient CommandInterface, vault, item string) ([]OpEnt
 	return items.Fields, nil
 }
 
+// CreateItem creates an item with the fields inside of the vault.
+func CreateItem(commandLineClient CommandInterface, vault, title string, fields []OpField) (*OpItem, error) {
+	templateFields := []map[string]any{}
+	for _, field := range fields {
+		if field.Type != FIELD_TYPE_TEXT {
+			redact.Add(field.Value)
+		}
+		templateFields = setField(templateFields, field.Label, field.Type, field.Value)
+	}
+
+	template, err := json.Marshal(map[string]any{"fields": templateFields})
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := runCommandWithStdin(commandLineClient, template, "item", "create", "--vault", vault, "--category", ITEM_CATEGORY, "--title", title, "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	var item OpItem
+
+	err = json.Unmarshal([]byte(output), &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
 func GetItems(commandLineClient CommandInterface, vault string) ([]OpItem, error) {
-	cmd := commandLineClient.Command(CLI_COMMAND, "item", "list", "--vault", vault, "--format", "json")
-	output, err := getOutput(cmd)
+	output, err := runCommand(commandLineClient, "item", "list", "--vault", vault, "--format", "json")
 	if err != nil {
 		return nil, err
 	}
@@ -153,10 +283,43 @@ func (client OnePassword) GetSecretAccessKey() (string, error) {
 	return client.getItem(fmt.Sprintf(OP_GET_ITEM_PATH, client.vault, client.item, client.secretAccessKeyField))
 }
 
+func (client *OnePassword) SetAccessKeyId(accessKeyId string) error {
+	return client.editFields(func(fields []map[string]any) []map[string]any {
+		return setField(fields, client.accessKeyField, FIELD_TYPE_TEXT, accessKeyId)
+	})
+}
+
+func (client *OnePassword) SetSecretAccessKey(secretAccessKey string) error {
+	redact.Add(secretAccessKey)
+	return client.editFields(func(fields []map[string]any) []map[string]any {
+		return setField(fields, client.secretAccessKeyField, FIELD_TYPE_PASSWORD, secretAccessKey)
+	})
+}
+
+// SetOTP adds a one-time password with the Base32 secret or otpauth:// URI to the item.
+func (client *OnePassword) SetOTP(otp string) error {
+	redact.Add(otp)
+	return client.editFields(func(fields []map[string]any) []map[string]any {
+		return setField(fields, OTP_FIELD_LABEL, FIELD_TYPE_OTP, otp)
+	})
+}
+
+// RemoveOTP removes the one-time password, that has been added with SetOTP.
+func (client *OnePassword) RemoveOTP() error {
+	return client.editFields(func(fields []map[string]any) []map[string]any {
+		kept := []map[string]any{}
+		for _, field := range fields {
+			if field["label"] != OTP_FIELD_LABEL || field["type"] != TEMPLATE_TYPE_OTP {
+				kept = append(kept, field)
+			}
+		}
+		return kept
+	})
+}
+
 func (client OnePassword) GetOTP() (string, error) {
 	// TODO: check if there is an option to retrieve a otp inside of a specific label when it comes to multiple otp inside of one item
-	cmd := client.commandLineClient.Command(CLI_COMMAND, "item", "get", client.item, "--vault", client.vault, "--otp")
-	return getOutput(cmd)
+	return runCommand(client.commandLineClient, "item", "get", client.item, "--vault", client.vault, "--otp")
 }
 
 func (client OnePassword) VaultAvailable() bool {
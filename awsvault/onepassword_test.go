@@ -2,21 +2,27 @@ package awsvault_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"nextunit/op2aws/awsvault"
+	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	outputReturnValue *string
+	outputReturnValue      *string
+	outputErrorReturnValue error
 
 	outputCallCount  int
 	commandCallCount int
 
 	commandInput []string
+	commandStdin []byte
 
 	testCases []testCaseStruct = []testCaseStruct{
 		{
@@ -50,7 +56,7 @@ type testCaseStruct struct {
 func (cmdClientTest) Output() ([]byte, error) {
 	outputCallCount++
 	if outputReturnValue == nil {
-		return nil, fmt.Errorf("Test error")
+		return nil, outputErrorReturnValue
 	}
 
 	return []byte(*outputReturnValue), nil
@@ -63,16 +69,23 @@ func (commandLineClientTest) Command(name string, arg ...string) awsvault.CmdInt
 	return &cmdClientTest{}
 }
 
+func (c commandLineClientTest) CommandWithStdin(stdin []byte, name string, arg ...string) awsvault.CmdInterface {
+	commandStdin = stdin
+	return c.Command(name, arg...)
+}
+
 func setupTestCases() {
 	// Set defaults
 	outputReturnValueString := "test-value"
 
 	outputReturnValue = &outputReturnValueString
+	outputErrorReturnValue = fmt.Errorf("Test error")
 
 	outputCallCount = 0
 	commandCallCount = 0
 
 	commandInput = []string{}
+	commandStdin = nil
 }
 
 func TestGetAccessKeyId(t *testing.T) {
@@ -171,3 +184,177 @@ func TestGetEntries(t *testing.T) {
 	assert.Equal(t, expectedOutput, items)
 	assert.Equal(t, []string{"op", "item", "get", "item-test", "--vault", "vault-test", "--format", "json"}, commandInput)
 }
+
+const TEST_ITEM_JSON = `{"id":"test-id","title":"test-item","fields":[{"id":"username","type":"STRING","label":"aws_access_key_id","value":"old-access-key-id"}]}`
+
+func getTemplateFields(t *testing.T) []map[string]any {
+	var template struct {
+		Fields []map[string]any `json:"fields"`
+	}
+	assert.Nil(t, json.Unmarshal(commandStdin, &template))
+
+	return template.Fields
+}
+
+func TestSetAccessKeys(t *testing.T) {
+	setupTestCases()
+	assert := assert.New(t)
+
+	outputString := TEST_ITEM_JSON
+	outputReturnValue = &outputString
+
+	vault := awsvault.NewOnePasswordVault(&commandLineClientTest{}, "test-vault", "test-item")
+
+	err := vault.SetAccessKeyId("new-access-key-id")
+	assert.Nil(err)
+	assert.Equal(2, commandCallCount, "The item should be read before it is changed")
+	assert.Equal([]string{"op", "item", "edit", "test-item", "--vault", "test-vault"}, commandInput)
+	assert.Equal([]map[string]any{
+		{"id": "username", "type": "STRING", "label": "aws_access_key_id", "value": "new-access-key-id"},
+	}, getTemplateFields(t))
+
+	vault.SetDefaults("aws.access=key", "aws\\secret", "TODO")
+	err = vault.SetSecretAccessKey("new-secret-access-key")
+	assert.Nil(err)
+	assert.Equal([]string{"op", "item", "edit", "test-item", "--vault", "test-vault"}, commandInput)
+	assert.NotContains(strings.Join(commandInput, " "), "new-secret-access-key", "The secret should not be passed as argument")
+	assert.Equal(map[string]any{"type": "CONCEALED", "label": "aws\\secret", "value": "new-secret-access-key"}, getTemplateFields(t)[1])
+
+	outputReturnValue = nil
+	err = vault.SetAccessKeyId("new-access-key-id")
+	assert.ErrorContains(err, "Test error")
+}
+
+func TestCreateItem(t *testing.T) {
+	setupTestCases()
+	assert := assert.New(t)
+
+	outputString := "{\"id\":\"test-id\",\"title\":\"test-item\",\"vault\":{\"id\":\"test-vault-id\",\"name\":\"test-vault\"}}"
+	outputReturnValue = &outputString
+
+	item, err := awsvault.CreateItem(&commandLineClientTest{}, "test-vault", "test-item", []awsvault.OpField{
+		{Label: "aws_access_key_id", Type: awsvault.FIELD_TYPE_TEXT, Value: "access-key-id"},
+		{Label: "aws.secret", Type: awsvault.FIELD_TYPE_PASSWORD, Value: "secret=access-key"},
+		{Label: "one-time password", Type: awsvault.FIELD_TYPE_OTP, Value: "otpauth://totp/test?secret=TEST"},
+	})
+
+	assert.Nil(err)
+	assert.Equal("test-id", item.Id)
+	assert.Equal("test-vault", item.Vault.Name)
+	assert.Equal([]string{
+		"op", "item", "create", "--vault", "test-vault", "--category", "API Credential", "--title", "test-item", "--format", "json",
+	}, commandInput)
+	assert.Equal([]map[string]any{
+		{"type": "STRING", "label": "aws_access_key_id", "value": "access-key-id"},
+		{"type": "CONCEALED", "label": "aws.secret", "value": "secret=access-key"},
+		{"type": "OTP", "label": "one-time password", "value": "otpauth://totp/test?secret=TEST"},
+	}, getTemplateFields(t))
+
+	outputReturnValue = nil
+	_, err = awsvault.CreateItem(&commandLineClientTest{}, "test-vault", "test-item", []awsvault.OpField{})
+	assert.ErrorContains(err, "Test error")
+}
+
+func TestSetOTP(t *testing.T) {
+	setupTestCases()
+	assert := assert.New(t)
+
+	outputString := TEST_ITEM_JSON
+	outputReturnValue = &outputString
+
+	vault := awsvault.NewOnePasswordVault(&commandLineClientTest{}, "test-vault", "test-item")
+
+	err := vault.SetOTP("GEZDGNBVGY3TQOJQ")
+	assert.Nil(err)
+	assert.Equal([]string{"op", "item", "edit", "test-item", "--vault", "test-vault"}, commandInput)
+	assert.NotContains(strings.Join(commandInput, " "), "GEZDGNBVGY3TQOJQ", "The seed should not be passed as argument")
+	assert.Equal(map[string]any{"type": "OTP", "label": "one-time password", "value": "GEZDGNBVGY3TQOJQ"}, getTemplateFields(t)[1])
+
+	outputString = string(commandStdin)
+	err = vault.RemoveOTP()
+	assert.Nil(err)
+	assert.Equal([]string{"op", "item", "edit", "test-item", "--vault", "test-vault"}, commandInput)
+	assert.Equal([]map[string]any{
+		{"id": "username", "type": "STRING", "label": "aws_access_key_id", "value": "old-access-key-id"},
+	}, getTemplateFields(t))
+}
+
+func TestErrorKinds(t *testing.T) {
+	testCasesErrors := []struct {
+		name         string
+		err          error
+		expectedKind error
+	}{
+		{
+			name:         "prompt cancelled",
+			err:          &exec.ExitError{Stderr: []byte("[ERROR] 2024/01/01 12:00:00 authorization prompt dismissed, please try again\n")},
+			expectedKind: awsvault.ErrPromptCancelled,
+		},
+		{
+			name:         "item not found",
+			err:          &exec.ExitError{Stderr: []byte("[ERROR] 2024/01/01 12:00:00 \"test-item\" isn't an item in the \"test-vault\" vault. Specify the item with its UUID, name, or domain.\n")},
+			expectedKind: awsvault.ErrNotFound,
+		},
+		{
+			name:         "field not found",
+			err:          &exec.ExitError{Stderr: []byte("[ERROR] 2024/01/01 12:00:00 could not read secret 'op://test-vault/test-item/test-field': error getting field: the item doesn't have a field named test-field\n")},
+			expectedKind: awsvault.ErrNotFound,
+		},
+		{
+			name:         "not signed in",
+			err:          &exec.ExitError{Stderr: []byte("[ERROR] 2024/01/01 12:00:00 You are not currently signed in. Please run `op signin --help` for instructions\n")},
+			expectedKind: awsvault.ErrVaultUnavailable,
+		},
+		{
+			name:         "unknown error",
+			err:          &exec.ExitError{Stderr: []byte("[ERROR] 2024/01/01 12:00:00 unexpected error\n")},
+			expectedKind: nil,
+		},
+		{
+			name:         "missing cli",
+			err:          &exec.Error{Name: "op", Err: exec.ErrNotFound},
+			expectedKind: awsvault.ErrVaultUnavailable,
+		},
+	}
+
+	for _, v := range testCasesErrors {
+		t.Run(v.name, func(t *testing.T) {
+			setupTestCases()
+			outputReturnValue = nil
+			outputErrorReturnValue = v.err
+
+			vault := awsvault.NewOnePasswordVault(&commandLineClientTest{}, "test-vault", "test-item")
+			_, err := vault.GetAccessKeyId()
+
+			assert.NotNil(t, err)
+			assert.ErrorIs(t, err, v.err, "The error of the command should be kept")
+			for _, kind := range []error{awsvault.ErrVaultUnavailable, awsvault.ErrPromptCancelled, awsvault.ErrNotFound} {
+				assert.Equal(t, kind == v.expectedKind, errors.Is(err, kind))
+			}
+		})
+	}
+}
+
+func TestCommandClientTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	start := time.Now()
+	_, err := awsvault.CommandClientTimeout{Timeout: 50 * time.Millisecond}.Command("sleep", "5").Output()
+
+	assert.NotNil(t, err, "The command should be killed after the timeout")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestCommandClientTimeoutWithChild(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	start := time.Now()
+	_, err := awsvault.CommandClientTimeout{Timeout: 50 * time.Millisecond}.Command("sh", "-c", "sleep 5 & sleep 5").Output()
+
+	assert.NotNil(t, err, "The command should be killed after the timeout")
+	assert.Less(t, time.Since(start), 2*time.Second, "A child, that keeps the output open, should not block the command")
+}
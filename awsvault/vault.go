@@ -1,6 +1,14 @@
 package awsvault
 
-import "os/exec"
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"time"
+)
+
+// COMMAND_WAIT_DELAY is the time until the output of a killed command is closed.
+const COMMAND_WAIT_DELAY = 500 * time.Millisecond
 
 type Vault interface {
 	GetAccessKeyId() (string, error)
@@ -8,12 +16,18 @@ type Vault interface {
 	GetOTP() (string, error)
 	GetSecretAccessKey() (string, error)
 	GetVault() string
+	RemoveOTP() error
+	SetAccessKeyId(accessKeyId string) error
 	SetDefaults(accessKeyField, secretAccessKeyField, mfaField string)
+	SetOTP(otp string) error
+	SetSecretAccessKey(secretAccessKey string) error
 	VaultAvailable() bool
 }
 
 type CommandInterface interface {
 	Command(name string, arg ...string) CmdInterface
+	// CommandWithStdin passes the input on stdin, so secrets don't show up inside of the process list.
+	CommandWithStdin(stdin []byte, name string, arg ...string) CmdInterface
 }
 
 type CmdInterface interface {
@@ -27,3 +41,43 @@ type CommandClientDefault struct {
 func (CommandClientDefault) Command(name string, arg ...string) CmdInterface {
 	return exec.Command(name, arg...)
 }
+
+func (CommandClientDefault) CommandWithStdin(stdin []byte, name string, arg ...string) CmdInterface {
+	cmd := exec.Command(name, arg...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	return cmd
+}
+
+// CommandClientTimeout kills the commands, that don't finish within the timeout.
+type CommandClientTimeout struct {
+	Timeout time.Duration
+}
+
+type timeoutCmd struct {
+	name    string
+	arg     []string
+	stdin   []byte
+	timeout time.Duration
+}
+
+func (c CommandClientTimeout) Command(name string, arg ...string) CmdInterface {
+	return &timeoutCmd{name: name, arg: arg, timeout: c.Timeout}
+}
+
+func (c CommandClientTimeout) CommandWithStdin(stdin []byte, name string, arg ...string) CmdInterface {
+	return &timeoutCmd{name: name, arg: arg, stdin: stdin, timeout: c.Timeout}
+}
+
+func (c *timeoutCmd) Output() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.name, c.arg...)
+	// Children of the killed command can keep the output open.
+	cmd.WaitDelay = COMMAND_WAIT_DELAY
+	if c.stdin != nil {
+		cmd.Stdin = bytes.NewReader(c.stdin)
+	}
+
+	return cmd.Output()
+}
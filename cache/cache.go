@@ -2,34 +2,66 @@ package cache
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/logger"
 	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
-const FILEMODE = os.FileMode(int(0777))
+const (
+	DIR_MODE       = os.FileMode(0700)
+	FILE_MODE      = os.FileMode(0600)
+	CACHE_VERSION  = 1
+	LOCK_DIR       = "locks"
+	QUARANTINE_DIR = "corrupt"
+)
+
+// ErrCache is returned, when the cache can't be read, locked or written. Invalid cache files are a cache miss.
+var ErrCache = errors.New("The credentials cache is not available")
 
 type AWSCredentialsCacheClient struct {
 	osClient    AWSCredentialsCacheOsClient
 	path        string
+	stateDir    string
 	vault       string
 	item        string
 	mfa         string
 	assume_role string
+	settings    []string
+}
+
+type cacheRecord struct {
+	Version     int
+	Checksum    string
+	Credentials *sts.Credentials
+	MFA         string `json:",omitempty"`
 }
 
 type AWSCredentialsCacheOsClient interface {
 	Stat(name string) (fs.FileInfo, error)
+	IsNotExist(err error) bool
 	MkdirAll(path string, perm fs.FileMode) error
 	WriteFile(filename string, data []byte, perm fs.FileMode) error
 	ReadFile(filename string) ([]byte, error)
 	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	Lock(name string) (AWSCredentialsCacheLock, error)
+}
+
+type AWSCredentialsCacheLock interface {
+	Unlock() error
 }
 
 func (cache AWSCredentialsCacheClient) checkCacheDir() {
@@ -38,24 +70,138 @@ func (cache AWSCredentialsCacheClient) checkCacheDir() {
 		return
 	}
 
-	cache.osClient.MkdirAll(cache.path, FILEMODE)
+	cache.osClient.MkdirAll(cache.path, DIR_MODE)
+}
+
+// getStateDir returns the directory of the locks and the invalid cache files.
+func (cache AWSCredentialsCacheClient) getStateDir() string {
+	if cache.stateDir == "" {
+		return cache.path
+	}
+
+	return cache.stateDir
+}
+
+func (cache AWSCredentialsCacheClient) getFileName() string {
+	key := fmt.Sprintf("%s-%s-%s-%s", cache.vault, cache.item, cache.mfa, cache.assume_role)
+	if len(cache.settings) > 0 {
+		key += "-" + strings.Join(cache.settings, "-")
+	}
+
+	filehash := md5.Sum([]byte(key))
+	return fmt.Sprintf("%x", string(filehash[:]))
 }
 
 func (cache AWSCredentialsCacheClient) getFilePath() string {
-	filehash := md5.Sum([]byte(fmt.Sprintf("%s-%s-%s-%s", cache.vault, cache.item, cache.mfa, cache.assume_role)))
-	return fmt.Sprintf("%s/%x", cache.path, string(filehash[:]))
+	return fmt.Sprintf("%s/%s", cache.path, cache.getFileName())
+}
+
+func getChecksum(credentials *sts.Credentials) (string, error) {
+	content, err := json.Marshal(credentials)
+	if err != nil {
+		return "", err
+	}
+
+	checksum := sha256.Sum256(content)
+	return hex.EncodeToString(checksum[:]), nil
+}
+
+func isComplete(credentials *sts.Credentials) bool {
+	return credentials != nil &&
+		credentials.AccessKeyId != nil &&
+		credentials.SecretAccessKey != nil &&
+		credentials.SessionToken != nil &&
+		credentials.Expiration != nil
+}
+
+// decodeRecord returns an error for every record, that can not be trusted.
+func decodeRecord(content []byte) (*cacheRecord, error) {
+	record := &cacheRecord{}
+	if err := json.Unmarshal(content, record); err != nil {
+		return nil, err
+	}
+
+	if record.Version != CACHE_VERSION {
+		return nil, fmt.Errorf("Unknown cache version %d", record.Version)
+	}
+
+	checksum, err := getChecksum(record.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum != record.Checksum {
+		return nil, fmt.Errorf("Invalid checksum of the cached credentials")
+	}
+
+	if !isComplete(record.Credentials) {
+		return nil, fmt.Errorf("Incomplete cached credentials")
+	}
+
+	return record, nil
+}
+
+// quarantine moves an invalid cache file into the state directory.
+func (cache AWSCredentialsCacheClient) quarantine(filepath string) {
+	quarantinePath := fmt.Sprintf("%s/%s", cache.getStateDir(), QUARANTINE_DIR)
+	err := cache.osClient.MkdirAll(quarantinePath, DIR_MODE)
+	if err == nil {
+		err = cache.osClient.Rename(filepath, fmt.Sprintf("%s/%s", quarantinePath, cache.getFileName()))
+	}
+	if err != nil {
+		cache.osClient.Remove(filepath)
+	}
 }
 
-func (cache AWSCredentialsCacheClient) Store(credentials *sts.Credentials) error {
+// Store writes the credentials with the serial number of the MFA device, that has been used for them.
+func (cache AWSCredentialsCacheClient) Store(credentials *sts.Credentials, mfa string) error {
 	cache.checkCacheDir()
 	filepath := cache.getFilePath()
 
-	content, err := json.Marshal(credentials)
+	checksum, err := getChecksum(credentials)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(cacheRecord{
+		Version:     CACHE_VERSION,
+		Checksum:    checksum,
+		Credentials: credentials,
+		MFA:         mfa,
+	})
 	if err != nil {
 		return err
 	}
 
-	return cache.osClient.WriteFile(filepath, content, FILEMODE)
+	tmpFilepath := fmt.Sprintf("%s.%d.tmp", filepath, os.Getpid())
+	err = cache.osClient.WriteFile(tmpFilepath, content, FILE_MODE)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCache, err)
+	}
+
+	err = cache.osClient.Rename(tmpFilepath, filepath)
+	if err != nil {
+		cache.osClient.Remove(tmpFilepath)
+		return fmt.Errorf("%w: %w", ErrCache, err)
+	}
+
+	logger.Debug("Stored the credentials inside of the cache", "path", filepath)
+	return nil
+}
+
+// Lock blocks until no other process holds the lock for the same credentials.
+func (cache AWSCredentialsCacheClient) Lock() (AWSCredentialsCacheLock, error) {
+	lockPath := fmt.Sprintf("%s/%s", cache.getStateDir(), LOCK_DIR)
+	if err := cache.osClient.MkdirAll(lockPath, DIR_MODE); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCache, err)
+	}
+
+	lock, err := cache.osClient.Lock(fmt.Sprintf("%s/%s.lock", lockPath, cache.getFileName()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCache, err)
+	}
+
+	return lock, nil
 }
 
 func (cache AWSCredentialsCacheClient) GetCache() (*sts.Credentials, error) {
@@ -64,25 +210,71 @@ func (cache AWSCredentialsCacheClient) GetCache() (*sts.Credentials, error) {
 
 	_, err := cache.osClient.Stat(filepath)
 	if err != nil {
-		return nil, nil
+		if cache.osClient.IsNotExist(err) {
+			logger.Info("Cache miss", "reason", "no cache file", "path", filepath)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %w", ErrCache, err)
 	}
 
 	content, err := cache.osClient.ReadFile(filepath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrCache, err)
 	}
 
-	credentials := &sts.Credentials{}
-	json.Unmarshal(content, credentials)
+	record, err := decodeRecord(content)
+	if err != nil {
+		logger.Info("Cache miss", "reason", "invalid cache file", "path", filepath, "error", err)
+		cache.quarantine(filepath)
+		return nil, nil
+	}
 
+	credentials := record.Credentials
 	if credentials.Expiration.Before(time.Now()) {
+		logger.Info("Cache miss", "reason", "expired", "path", filepath, "expiration", credentials.Expiration.Format(time.RFC3339))
 		cache.osClient.Remove(filepath)
 		return nil, nil
 	}
 
+	redact.Add(aws.StringValue(credentials.SecretAccessKey), aws.StringValue(credentials.SessionToken))
+	logger.Info("Cache hit", "path", filepath, "expiration", credentials.Expiration.Format(time.RFC3339))
 	return credentials, nil
 }
 
+// Peek returns the valid cached credentials without the lock and without changing any file.
+func (cache AWSCredentialsCacheClient) Peek() *sts.Credentials {
+	record := cache.peekRecord()
+	if record == nil {
+		return nil
+	}
+
+	return record.Credentials
+}
+
+// PeekMFA returns the MFA device of the valid cached credentials.
+func (cache AWSCredentialsCacheClient) PeekMFA() string {
+	record := cache.peekRecord()
+	if record == nil {
+		return ""
+	}
+
+	return record.MFA
+}
+
+func (cache AWSCredentialsCacheClient) peekRecord() *cacheRecord {
+	content, err := cache.osClient.ReadFile(cache.getFilePath())
+	if err != nil {
+		return nil
+	}
+
+	record, err := decodeRecord(content)
+	if err != nil || record.Credentials.Expiration.Before(time.Now()) {
+		return nil
+	}
+
+	return record
+}
+
 func (cache *AWSCredentialsCacheClient) GenerateFromOP(client awsvault.Vault) {
 	cache.vault = client.GetVault()
 	cache.item = client.GetItem()
@@ -109,6 +301,16 @@ func (cache *AWSCredentialsCacheClient) AssumeRole(assume_role string) {
 	cache.assume_role = assume_role
 }
 
+// Settings adds the settings, that change the credentials, to the key of the cache.
+func (cache *AWSCredentialsCacheClient) Settings(settings ...string) {
+	cache.settings = settings
+}
+
+// StateDir sets the directory of the locks and the invalid cache files.
+func (cache *AWSCredentialsCacheClient) StateDir(stateDir string) {
+	cache.stateDir = stateDir
+}
+
 func New(osClient AWSCredentialsCacheOsClient, path string) *AWSCredentialsCacheClient {
 	return &AWSCredentialsCacheClient{osClient: osClient, path: path}
 }
@@ -1,15 +1,21 @@
 package cache_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"nextunit/op2aws/awsvault"
 	"nextunit/op2aws/cache"
 	"nextunit/op2aws/opaws"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,17 +23,25 @@ import (
 var (
 	expirationDate time.Time
 
-	statReturnValue      fs.FileInfo
-	mkdirAllReturnValue  error
-	writeFileReturnValue error
-	readFileReturnValue  []byte
-	removeReturnValue    error
-
-	statCallCount      int
-	mkdirAllCallCount  int
-	writeFileCallCount int
-	readFileCallCount  int
-	removeCallCount    int
+	statReturnValue       fs.FileInfo
+	isNotExistReturnValue bool
+	mkdirAllReturnValue   error
+	writeFileReturnValue  error
+	readFileReturnValue   []byte
+	removeReturnValue     error
+	renameReturnValue     error
+	lockReturnValue       error
+	unlockReturnValue     error
+
+	statCallCount       int
+	isNotExistCallCount int
+	mkdirAllCallCount   int
+	writeFileCallCount  int
+	readFileCallCount   int
+	removeCallCount     int
+	renameCallCount     int
+	lockCallCount       int
+	unlockCallCount     int
 
 	statInput     []string
 	mkdirAllInput struct {
@@ -41,6 +55,8 @@ var (
 	}
 	readFileInput string
 	removeInput   string
+	renameInput   []string
+	lockInput     string
 
 	testCasesGetCache = []testCaseModel{
 		{
@@ -60,6 +76,12 @@ var (
 	}
 )
 
+type testCacheRecord struct {
+	Version     int
+	Checksum    string
+	Credentials *sts.Credentials
+}
+
 type testCaseModel struct {
 	Vault            string
 	Item             string
@@ -79,6 +101,10 @@ type testCredentialsCacheOsClientMock struct {
 	cache.AWSCredentialsCacheOsClient
 }
 
+type testCredentialsCacheLockMock struct {
+	cache.AWSCredentialsCacheLock
+}
+
 func (testCredentialsCacheOsClientMock) Stat(name string) (fs.FileInfo, error) {
 	statCallCount++
 	statInput = append(statInput, name)
@@ -88,6 +114,11 @@ func (testCredentialsCacheOsClientMock) Stat(name string) (fs.FileInfo, error) {
 	return statReturnValue, nil
 }
 
+func (testCredentialsCacheOsClientMock) IsNotExist(err error) bool {
+	isNotExistCallCount++
+	return isNotExistReturnValue
+}
+
 func (testCredentialsCacheOsClientMock) MkdirAll(path string, perm fs.FileMode) error {
 	mkdirAllCallCount++
 	mkdirAllInput = struct {
@@ -129,6 +160,26 @@ func (testCredentialsCacheOsClientMock) Remove(name string) error {
 	return removeReturnValue
 }
 
+func (testCredentialsCacheOsClientMock) Rename(oldpath, newpath string) error {
+	renameCallCount++
+	renameInput = []string{oldpath, newpath}
+	return renameReturnValue
+}
+
+func (testCredentialsCacheOsClientMock) Lock(name string) (cache.AWSCredentialsCacheLock, error) {
+	lockCallCount++
+	lockInput = name
+	if lockReturnValue != nil {
+		return nil, lockReturnValue
+	}
+	return &testCredentialsCacheLockMock{}, nil
+}
+
+func (testCredentialsCacheLockMock) Unlock() error {
+	unlockCallCount++
+	return unlockReturnValue
+}
+
 func init() {
 	setupTestCases()
 	vault := awsvault.NewOnePasswordVault(&awsvault.CommandClientDefault{}, "test-vault-3", "test-item-3")
@@ -142,21 +193,50 @@ func init() {
 	})
 }
 
+func getCacheFileContent(version int, credentialsJson string) []byte {
+	credentials := &sts.Credentials{}
+	json.Unmarshal([]byte(credentialsJson), credentials)
+
+	content, _ := json.Marshal(credentials)
+	checksum := sha256.Sum256(content)
+
+	record, _ := json.Marshal(testCacheRecord{
+		Version:     version,
+		Checksum:    hex.EncodeToString(checksum[:]),
+		Credentials: credentials,
+	})
+	return record
+}
+
+func getCacheFileCredentials(content []byte) *sts.Credentials {
+	record := &testCacheRecord{}
+	json.Unmarshal(content, record)
+	return record.Credentials
+}
+
 func setupTestCases() {
 	expirationDate := time.Now().Add(time.Hour)
 	currentTimeString, _ := expirationDate.UTC().MarshalText()
 
 	statReturnValue = &testAwsFileInfoMock{}
+	isNotExistReturnValue = false
 	mkdirAllReturnValue = nil
 	writeFileReturnValue = nil
-	readFileReturnValue = []byte(fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(currentTimeString)))
+	readFileReturnValue = getCacheFileContent(cache.CACHE_VERSION, fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(currentTimeString)))
 	removeReturnValue = nil
+	renameReturnValue = nil
+	lockReturnValue = nil
+	unlockReturnValue = nil
 
 	statCallCount = 0
+	isNotExistCallCount = 0
 	mkdirAllCallCount = 0
 	writeFileCallCount = 0
 	readFileCallCount = 0
 	removeCallCount = 0
+	renameCallCount = 0
+	lockCallCount = 0
+	unlockCallCount = 0
 
 	statInput = []string{}
 	mkdirAllInput = struct {
@@ -170,6 +250,8 @@ func setupTestCases() {
 	}{}
 	readFileInput = ""
 	removeInput = ""
+	renameInput = []string{}
+	lockInput = ""
 }
 
 func TestGetCache(t *testing.T) {
@@ -200,10 +282,7 @@ func TestGetCache(t *testing.T) {
 
 			assert.Nil(err)
 
-			stsCredentials := &sts.Credentials{}
-			json.Unmarshal(readFileReturnValue, stsCredentials)
-
-			assert.Equal(stsCredentials, credentials)
+			assert.Equal(getCacheFileCredentials(readFileReturnValue), credentials)
 
 			assert.Equal("test-path", statInput[0], "First check is checking if directory path is existing")
 			assert.Equal(0, mkdirAllCallCount, "MkdirAll should not be called, because the Stat function is not returning an error")
@@ -220,7 +299,7 @@ func TestGetCache(t *testing.T) {
 
 			currentTime := time.Now().Add(-1 * time.Hour)
 			currentTimeString, _ := currentTime.UTC().MarshalText()
-			readFileReturnValue = []byte(fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(currentTimeString)))
+			readFileReturnValue = getCacheFileContent(cache.CACHE_VERSION, fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(currentTimeString)))
 
 			client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
 
@@ -279,18 +358,316 @@ func TestStore(t *testing.T) {
 				client.GenerateFromOPAWS(v.OpAws)
 			}
 
-			err := client.Store(credentials)
+			err := client.Store(credentials, "")
 
 			assert.Nil(err)
 
-			stsCredentials, err := json.Marshal(credentials)
+			stsCredentials := getCacheFileContent(cache.CACHE_VERSION, "{}")
 
 			assert.Equal("test-path", statInput[0], "First check is checking if directory path is existing")
 			assert.Equal(0, mkdirAllCallCount, "MkdirAll should not be called, because the Stat function is not returning an error")
 			assert.Equal(1, writeFileCallCount, "WriteFile should be called")
 
-			assert.Equal(v.ExpectedFileName, writeFileInput.filename)
+			assert.Equal(1, renameCallCount, "Rename should be called")
+
+			tmpFileName := fmt.Sprintf("%s.%d.tmp", v.ExpectedFileName, os.Getpid())
+			assert.Equal(tmpFileName, writeFileInput.filename, "The credentials should be written into a temporary file first")
 			assert.Equal(stsCredentials, writeFileInput.data)
+			assert.Equal(cache.FILE_MODE, writeFileInput.perm)
+			assert.Equal([]string{tmpFileName, v.ExpectedFileName}, renameInput)
 		})
 	}
 }
+
+func TestStoreErrors(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+
+	writeFileReturnValue = fmt.Errorf("test-error WriteFile")
+	err := client.Store(&sts.Credentials{}, "")
+	assert.ErrorContains(err, "test-error WriteFile")
+	assert.ErrorIs(err, cache.ErrCache)
+	assert.Equal(0, renameCallCount, "Rename should not be called, when the temporary file has not been written")
+
+	writeFileReturnValue = nil
+	renameReturnValue = fmt.Errorf("test-error Rename")
+	err = client.Store(&sts.Credentials{}, "")
+	assert.ErrorContains(err, "test-error Rename")
+	assert.Equal(1, removeCallCount, "The temporary file should be removed, when it can not be renamed")
+	assert.Equal(writeFileInput.filename, removeInput)
+}
+
+func TestLock(t *testing.T) {
+	for i, v := range testCasesGetCache {
+		t.Run(fmt.Sprintf("Running Lock test %d", i), func(t *testing.T) {
+			assert := assert.New(t)
+			setupTestCases()
+
+			client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+			client.StateDir("test-state")
+			if v.AwsVault == nil {
+				client.Vault(v.Vault)
+				client.Item(v.Item)
+			} else {
+				client.GenerateFromOP(v.AwsVault)
+			}
+			if v.OpAws == nil {
+				client.MFA(v.Mfa)
+				client.AssumeRole(v.AssumeRole)
+			} else {
+				client.GenerateFromOPAWS(v.OpAws)
+			}
+
+			lock, err := client.Lock()
+			assert.Nil(err)
+			assert.Equal(1, lockCallCount)
+			assert.Equal("test-state/"+cache.LOCK_DIR, mkdirAllInput.path, "The locks should be inside of the state directory")
+			assert.Equal(cache.DIR_MODE, mkdirAllInput.perm)
+			assert.Equal(strings.Replace(v.ExpectedFileName, "test-path/", "test-state/"+cache.LOCK_DIR+"/", 1)+".lock", lockInput, "There should be one lock per cache file")
+
+			assert.Nil(lock.Unlock())
+			assert.Equal(1, unlockCallCount)
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+	client.Vault("test-vault")
+	client.Item("test-item")
+	client.MFA("test-mfa")
+	client.AssumeRole("test-assume-role")
+
+	_, err := client.Lock()
+	assert.Nil(err)
+	expectedLock := strings.Replace(testCasesGetCache[0].ExpectedFileName, "test-path/", "test-path/"+cache.LOCK_DIR+"/", 1) + ".lock"
+	assert.Equal(expectedLock, lockInput, "Without settings the key should not change")
+
+	client.Settings("test-session", "eu-central-1", "1h0m0s")
+	_, err = client.Lock()
+	assert.Nil(err)
+	withSettings := lockInput
+	assert.NotEqual(expectedLock, withSettings)
+
+	client.Settings("test-session", "eu-central-1", "2h0m0s")
+	_, err = client.Lock()
+	assert.Nil(err)
+	assert.NotEqual(withSettings, lockInput, "Another duration should use another cache file")
+}
+
+func TestLockError(t *testing.T) {
+	setupTestCases()
+	lockReturnValue = fmt.Errorf("test-error Lock")
+
+	client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+	lock, err := client.Lock()
+
+	assert.ErrorContains(t, err, "test-error Lock")
+	assert.Nil(t, lock)
+
+	setupTestCases()
+	mkdirAllReturnValue = fmt.Errorf("test-error MkdirAll")
+	lock, err = client.Lock()
+
+	assert.ErrorIs(t, err, cache.ErrCache)
+	assert.Nil(t, lock)
+	assert.Equal(t, 0, lockCallCount, "The lock should not be taken without its directory")
+}
+
+func TestDefaultLock(t *testing.T) {
+	assert := assert.New(t)
+
+	stateDir := t.TempDir()
+	client := cache.New(&cache.AWSCredentialsCacheOsClientDefault{}, t.TempDir())
+	client.StateDir(stateDir)
+	lock, err := client.Lock()
+	assert.Nil(err)
+
+	info, err := os.Stat(filepath.Join(stateDir, cache.LOCK_DIR))
+	assert.Nil(err)
+	assert.Equal(cache.DIR_MODE, info.Mode().Perm())
+
+	locked := make(chan struct{})
+	go func() {
+		secondLock, err := client.Lock()
+		assert.Nil(err)
+		close(locked)
+		secondLock.Unlock()
+	}()
+
+	select {
+	case <-locked:
+		assert.Fail("The second lock should wait until the first lock is released")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Nil(lock.Unlock())
+	<-locked
+}
+
+func TestGetCacheInvalidRecords(t *testing.T) {
+	expirationDate, _ := time.Now().Add(time.Hour).UTC().MarshalText()
+	validCredentials := fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(expirationDate))
+	validRecord := getCacheFileContent(cache.CACHE_VERSION, validCredentials)
+
+	testCasesInvalidRecords := map[string][]byte{
+		"truncated file":        validRecord[:len(validRecord)/2],
+		"empty file":            {},
+		"unversioned file":      []byte(validCredentials),
+		"unknown version":       getCacheFileContent(cache.CACHE_VERSION+1, validCredentials),
+		"invalid checksum":      []byte(strings.Replace(string(validRecord), "secret-access-key", "secret-access-kez", 1)),
+		"missing expiration":    getCacheFileContent(cache.CACHE_VERSION, "{\"AccessKeyId\":\"access-key-id\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}"),
+		"missing session token": getCacheFileContent(cache.CACHE_VERSION, fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\"}", string(expirationDate))),
+		"missing credentials":   []byte(fmt.Sprintf("{\"Version\":%d}", cache.CACHE_VERSION)),
+	}
+
+	for name, content := range testCasesInvalidRecords {
+		t.Run(fmt.Sprintf("Running GetCache test with %s", name), func(t *testing.T) {
+			assert := assert.New(t)
+			setupTestCases()
+			readFileReturnValue = content
+
+			client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+			client.StateDir("test-state")
+			credentials, err := client.GetCache()
+
+			assert.Nil(err, "Invalid cache files should be handled as cache miss")
+			assert.Nil(credentials)
+			assert.Equal(1, renameCallCount, "The invalid cache file should be moved into quarantine")
+			assert.Equal([]string{readFileInput, strings.Replace(readFileInput, "test-path/", "test-state/"+cache.QUARANTINE_DIR+"/", 1)}, renameInput)
+			assert.Equal("test-state/"+cache.QUARANTINE_DIR, mkdirAllInput.path, "The invalid cache file should be moved into the state directory")
+			assert.Equal(cache.DIR_MODE, mkdirAllInput.perm)
+			assert.Equal(0, removeCallCount)
+		})
+	}
+}
+
+func TestGetCacheInvalidRecordQuarantineError(t *testing.T) {
+	setupTestCases()
+	readFileReturnValue = []byte("{")
+	renameReturnValue = fmt.Errorf("test-error Rename")
+
+	client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+	credentials, err := client.GetCache()
+
+	assert.Nil(t, err)
+	assert.Nil(t, credentials)
+	assert.Equal(t, 1, removeCallCount, "The invalid cache file should be removed, when it can not be moved into quarantine")
+	assert.Equal(t, readFileInput, removeInput)
+
+	setupTestCases()
+	readFileReturnValue = []byte("{")
+	mkdirAllReturnValue = fmt.Errorf("test-error MkdirAll")
+
+	credentials, err = client.GetCache()
+
+	assert.Nil(t, err)
+	assert.Nil(t, credentials)
+	assert.Equal(t, 0, renameCallCount, "The invalid cache file should not be moved without the quarantine directory")
+	assert.Equal(t, 1, removeCallCount)
+}
+
+func TestGetCacheStatErrors(t *testing.T) {
+	t.Run("Running GetCache test with a missing cache file", func(t *testing.T) {
+		assert := assert.New(t)
+		setupTestCases()
+		statReturnValue = nil
+		isNotExistReturnValue = true
+
+		client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+		credentials, err := client.GetCache()
+
+		assert.Nil(err)
+		assert.Nil(credentials)
+		assert.Equal(1, isNotExistCallCount)
+		assert.Equal(0, readFileCallCount, "ReadFile should not be called for a missing cache file")
+	})
+	t.Run("Running GetCache test with a failing Stat", func(t *testing.T) {
+		assert := assert.New(t)
+		setupTestCases()
+		statReturnValue = nil
+
+		client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+		credentials, err := client.GetCache()
+
+		assert.ErrorContains(err, "test-error stat", "Errors other than a missing file should be reported")
+		assert.Nil(credentials)
+		assert.Equal(0, readFileCallCount)
+	})
+	t.Run("Running GetCache test with a failing ReadFile", func(t *testing.T) {
+		assert := assert.New(t)
+		setupTestCases()
+		readFileReturnValue = nil
+
+		client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+		credentials, err := client.GetCache()
+
+		assert.ErrorContains(err, "test-error ReadFile")
+		assert.Nil(credentials)
+		assert.Equal(0, renameCallCount)
+		assert.Equal(0, removeCallCount)
+	})
+}
+
+func TestPeek(t *testing.T) {
+	expiredDate, _ := time.Now().Add(-1 * time.Hour).UTC().MarshalText()
+
+	testCasesPeek := map[string]struct {
+		content  []byte
+		missing  bool
+		expected bool
+	}{
+		"valid file":   {expected: true},
+		"missing file": {missing: true},
+		"invalid file": {content: []byte("{")},
+		"expired file": {content: getCacheFileContent(cache.CACHE_VERSION, fmt.Sprintf("{\"AccessKeyId\":\"access-key-id\",\"Expiration\":\"%s\",\"SecretAccessKey\":\"secret-access-key\",\"SessionToken\":\"session-token\"}", string(expiredDate)))},
+	}
+
+	for name, v := range testCasesPeek {
+		t.Run(fmt.Sprintf("Running Peek test with %s", name), func(t *testing.T) {
+			assert := assert.New(t)
+			setupTestCases()
+			if v.content != nil {
+				readFileReturnValue = v.content
+			}
+			if v.missing {
+				readFileReturnValue = nil
+			}
+
+			client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+			credentials := client.Peek()
+
+			assert.Equal(v.expected, credentials != nil)
+			assert.Equal(0, mkdirAllCallCount, "Peek should not create the cache directory")
+			assert.Equal(0, removeCallCount, "Peek should not remove the cache file")
+			assert.Equal(0, renameCallCount, "Peek should not move the cache file into quarantine")
+			assert.Equal(0, lockCallCount, "Peek should not lock the cache")
+		})
+	}
+}
+
+func TestPeekMFA(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	client := cache.New(&testCredentialsCacheOsClientMock{}, "test-path")
+	client.MFA(opaws.MFA_AUTO)
+	assert.Nil(client.Store(&sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}, "arn:aws:iam::123456789012:mfa/detected"))
+
+	readFileReturnValue = writeFileInput.data
+	assert.Equal("arn:aws:iam::123456789012:mfa/detected", client.PeekMFA(), "The detected MFA device should be stored instead of auto")
+	assert.NotNil(client.Peek())
+
+	readFileReturnValue = getCacheFileContent(cache.CACHE_VERSION, "{}")
+	assert.Equal("", client.PeekMFA(), "Invalid cache files have no MFA device")
+}
@@ -10,10 +10,20 @@ type AWSCredentialsCacheOsClientDefault struct {
 	AWSCredentialsCacheOsClient
 }
 
+type AWSCredentialsCacheLockDefault struct {
+	AWSCredentialsCacheLock
+
+	file *os.File
+}
+
 func (AWSCredentialsCacheOsClientDefault) Stat(name string) (fs.FileInfo, error) {
 	return os.Stat(name)
 }
 
+func (AWSCredentialsCacheOsClientDefault) IsNotExist(err error) bool {
+	return os.IsNotExist(err)
+}
+
 func (AWSCredentialsCacheOsClientDefault) MkdirAll(path string, perm fs.FileMode) error {
 	return os.MkdirAll(path, perm)
 }
@@ -29,3 +39,26 @@ func (AWSCredentialsCacheOsClientDefault) ReadFile(filename string) ([]byte, err
 func (AWSCredentialsCacheOsClientDefault) Remove(name string) error {
 	return os.Remove(name)
 }
+
+func (AWSCredentialsCacheOsClientDefault) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (AWSCredentialsCacheOsClientDefault) Lock(name string) (AWSCredentialsCacheLock, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, FILE_MODE)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &AWSCredentialsCacheLockDefault{file: file}, nil
+}
+
+func (lock AWSCredentialsCacheLockDefault) Unlock() error {
+	defer lock.file.Close()
+	return unlockFile(lock.file)
+}
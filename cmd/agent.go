@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"nextunit/op2aws/agent"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func getProfileDescription(profile *opaws.OpProfile) string {
+	if profile.Name != "" {
+		return profile.Name
+	}
+
+	return fmt.Sprintf("%s/%s", profile.Vault, profile.Item)
+}
+
+func runAgentStartCommand(profileNames []string, idleTimeout time.Duration) {
+	client := agent.NewClient(config.GetAgentSocketPath())
+	if _, err := client.GetStatus(); err == nil {
+		handleError(fmt.Errorf("The agent is already running"))
+	}
+
+	executable, err := os.Executable()
+	handleError(err)
+
+	args := append([]string{config.COMMAND_AGENT, "run", "--idle-timeout", idleTimeout.String()}, profileNames...)
+	process := exec.Command(executable, args...)
+	handleError(process.Start())
+	pid := process.Process.Pid
+	process.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		if _, err := client.GetStatus(); err == nil {
+			fmt.Printf("Started the agent (pid %d) on %s.\n", pid, config.GetAgentSocketPath())
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	handleError(fmt.Errorf("The agent did not start. Run `%s %s run` to see the error", config.COMMAND_ROOT, config.COMMAND_AGENT))
+}
+
+func runAgentRunCommand(profileNames []string, idleTimeout time.Duration) {
+	a := agent.New(getCredentialsWithSource)
+	a.IdleTimeout(idleTimeout)
+	a.RecordRefreshes(recordHistory)
+
+	for _, profileName := range profileNames {
+		a.Add(loadProfile(profileName))
+	}
+
+	socketPath := config.GetAgentSocketPath()
+	handleError(os.MkdirAll(filepath.Dir(socketPath), 0700))
+
+	// The socket of an agent, that has not been stopped cleanly, is removed.
+	if _, err := agent.NewClient(socketPath).GetStatus(); err == nil {
+		handleError(fmt.Errorf("The agent is already running"))
+	} else if errors.Is(err, agent.ErrAgentUnavailable) {
+		os.Remove(socketPath)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	handleError(err)
+	handleError(os.Chmod(socketPath, 0600))
+
+	// The agent keeps running, when the terminal it has been started in is closed.
+	signal.Ignore(syscall.SIGHUP)
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		a.Close()
+	}()
+
+	handleError(a.Serve(listener))
+}
+
+func runAgentStatusCommand() {
+	status, err := agent.NewClient(config.GetAgentSocketPath()).GetStatus()
+	handleError(err)
+
+	fmt.Printf("The agent is running since %s.\n\n", status.StartedAt.Local().Format(time.RFC1123))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tASSUME ROLE\tEXPIRATION\tLAST ERROR")
+	for _, p := range status.Profiles {
+		expiration := "-"
+		if p.Expiration != nil {
+			expiration = p.Expiration.Local().Format(time.RFC1123)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", getProfileDescription(p.Profile), p.Profile.AssumeRole, expiration, p.LastError)
+	}
+	w.Flush()
+}
+
+func runAgentStopCommand() {
+	handleError(agent.NewClient(config.GetAgentSocketPath()).Stop())
+	fmt.Println("Stopped the agent.")
+}
+
+func addAgentCmd() {
+	var idleTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_AGENT,
+		Short: "Runs an agent in the background, that keeps the credentials of profiles warm",
+		Long:  "The agent runs in the background and refreshes the credentials of profiles before they expire, so that no command has to wait for 1password or a MFA code.\n`" + config.COMMAND_ROOT + " " + config.COMMAND_CLI + "` gets the credentials from the agent, when it is running.",
+	}
+
+	startCmd := &cobra.Command{
+		Use:   "start [profile...]",
+		Short: "Starts the agent in the background",
+
+		ValidArgsFunction: completeProfiles,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAgentStartCommand(args, idleTimeout)
+		},
+	}
+	startCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", agent.DEFAULT_IDLE_TIMEOUT, "The agent stops, when it didn't get any request within this duration. 0 disables the timeout")
+
+	runCmd := &cobra.Command{
+		Use:    "run [profile...]",
+		Short:  "Runs the agent in the foreground",
+		Hidden: true,
+
+		ValidArgsFunction: completeProfiles,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAgentRunCommand(args, idleTimeout)
+		},
+	}
+	runCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", agent.DEFAULT_IDLE_TIMEOUT, "The agent stops, when it didn't get any request within this duration. 0 disables the timeout")
+
+	cmd.AddCommand(startCmd, runCmd, &cobra.Command{
+		Use:   "status",
+		Short: "Shows the status of the agent",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAgentStatusCommand()
+		},
+	}, &cobra.Command{
+		Use:   "stop",
+		Short: "Stops the agent",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAgentStopCommand()
+		},
+	})
+	rootCMD.AddCommand(cmd)
+}
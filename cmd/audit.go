@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const OUTPUT_CSV = "csv"
+
+type auditResult struct {
+	Vault           string
+	Item            string
+	UserName        string     `json:",omitempty"`
+	AccessKeyId     string     `json:",omitempty"`
+	KeyCreated      *time.Time `json:",omitempty"`
+	KeyAgeDays      int
+	LastUsed        *time.Time `json:",omitempty"`
+	LastUsedService string     `json:",omitempty"`
+	UserMFA         bool
+	ItemOTP         bool
+	Error           string `json:",omitempty"`
+}
+
+func formatAuditTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// getAuditEntries returns whether the item contains both access key fields and a one-time password.
+func getAuditEntries(entries []awsvault.OpEntry, labelAccessKey, labelSecretAccessKey string) (bool, bool) {
+	hasAccessKey, hasSecretAccessKey, hasOTP := false, false, false
+	for _, entry := range entries {
+		hasAccessKey = hasAccessKey || entry.Label == labelAccessKey
+		hasSecretAccessKey = hasSecretAccessKey || entry.Label == labelSecretAccessKey
+		hasOTP = hasOTP || entry.Type == "OTP"
+	}
+
+	return hasAccessKey && hasSecretAccessKey, hasOTP
+}
+
+func auditItem(vault awsvault.OpVault, item awsvault.OpItem, hasOTP bool, labelAccessKey, labelSecretAccessKey string) auditResult {
+	result := auditResult{Vault: vault.Name, Item: item.Title, ItemOTP: hasOTP}
+
+	// The ids are used, since the names of items don't have to be unique.
+	opClient := getVaultClient(&opaws.OpProfile{
+		Vault:                vault.Id,
+		Item:                 item.Id,
+		LabelAccessKey:       labelAccessKey,
+		LabelSecretAccessKey: labelSecretAccessKey,
+	})
+	report, err := opaws.New(opClient, &opaws.OpAwsDefaultInput{}).GetAccessKeyReport()
+	if err != nil {
+		result.Error = redact.String(err.Error())
+		return result
+	}
+
+	result.UserName = report.UserName
+	result.AccessKeyId = report.AccessKeyId
+	result.KeyCreated = &report.CreateDate
+	result.KeyAgeDays = int(time.Since(report.CreateDate).Hours() / 24)
+	result.LastUsed = report.LastUsedDate
+	result.LastUsedService = report.LastUsedService
+	result.UserMFA = report.MFAEnabled
+	return result
+}
+
+func printAuditResults(results []auditResult, output string) {
+	if output == OUTPUT_JSON {
+		content, err := json.MarshalIndent(results, "", "  ")
+		handleError(err)
+		fmt.Println(string(content))
+		return
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"vault", "item", "user_name", "access_key_id", "key_created", "key_age_days", "last_used", "last_used_service", "user_mfa", "item_otp", "error"})
+	for _, r := range results {
+		keyAgeDays := ""
+		if r.KeyCreated != nil {
+			keyAgeDays = strconv.Itoa(r.KeyAgeDays)
+		}
+
+		w.Write([]string{
+			r.Vault,
+			r.Item,
+			r.UserName,
+			r.AccessKeyId,
+			formatAuditTime(r.KeyCreated),
+			keyAgeDays,
+			formatAuditTime(r.LastUsed),
+			r.LastUsedService,
+			strconv.FormatBool(r.UserMFA),
+			strconv.FormatBool(r.ItemOTP),
+			r.Error,
+		})
+	}
+	w.Flush()
+	handleError(w.Error())
+}
+
+func runAuditCommand(vaultNames []string, labelAccessKey, labelSecretAccessKey, output string) {
+	if output != OUTPUT_JSON && output != OUTPUT_CSV {
+		handleError(fmt.Errorf("The output format %s is not supported, use %s or %s", output, OUTPUT_JSON, OUTPUT_CSV))
+	}
+
+	commandClient := &awsvault.CommandClientDefault{}
+	vaults, err := awsvault.GetVaults(commandClient)
+	handleError(err)
+
+	selectedVaults := map[string]bool{}
+	for _, name := range vaultNames {
+		selectedVaults[name] = true
+	}
+
+	results := []auditResult{}
+	for _, vault := range vaults {
+		if len(selectedVaults) != 0 && !selectedVaults[vault.Name] && !selectedVaults[vault.Id] {
+			continue
+		}
+
+		items, err := awsvault.GetItems(commandClient, vault.Id)
+		handleError(err)
+
+		for _, item := range items {
+			entries, err := awsvault.GetEntries(commandClient, vault.Id, item.Id)
+			if err != nil {
+				results = append(results, auditResult{Vault: vault.Name, Item: item.Title, Error: redact.String(err.Error())})
+				continue
+			}
+
+			isAwsItem, hasOTP := getAuditEntries(entries, labelAccessKey, labelSecretAccessKey)
+			if !isAwsItem {
+				continue
+			}
+
+			logger.Info("Auditing the item", "vault", vault.Name, "item", item.Title)
+			results = append(results, auditItem(vault, item, hasOTP, labelAccessKey, labelSecretAccessKey))
+		}
+	}
+
+	printAuditResults(results, output)
+}
+
+func addAuditCmd() {
+	var vaultNames []string
+	var labelAccessKey string
+	var labelSecretAccessKey string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_AUDIT,
+		Short: "Reports the access keys of all AWS items inside of 1password",
+		Long:  "Scans the vaults for items with AWS access keys and reports the age and the last use of each access key, whether the IAM user has a MFA device and whether the item contains a one-time password.\nThe access keys need the permissions iam:ListAccessKeys, iam:GetAccessKeyLastUsed and iam:ListMFADevices for their own user.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			profile := &opaws.OpProfile{LabelAccessKey: labelAccessKey, LabelSecretAccessKey: labelSecretAccessKey}
+			resolveProfile(profile)
+			runAuditCommand(vaultNames, profile.LabelAccessKey, profile.LabelSecretAccessKey, output)
+		},
+	}
+	cmd.Flags().StringSliceVar(&vaultNames, "vault", []string{}, "The vaults to scan. All vaults are scanned, when it is not set")
+	cmd.Flags().StringVarP(&labelAccessKey, "label-accesskey", "k", "", "To override the label field name in 1password for the AWS_ACCESS_KEY_ID (default \""+awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	cmd.Flags().StringVarP(&labelSecretAccessKey, "label-secret-accesskey", "s", "", "To override the label field name in 1password for the AWS_SECRET_ACCESS_KEY (default \""+awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	cmd.Flags().StringVarP(&output, "output", "o", OUTPUT_JSON, "The output format: json or csv")
+	cmd.RegisterFlagCompletionFunc("vault", completeVaultFlag)
+	rootCMD.AddCommand(cmd)
+}
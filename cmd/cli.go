@@ -4,52 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"nextunit/op2aws/awsvault"
-	"nextunit/op2aws/cache"
 	"nextunit/op2aws/config"
 	"nextunit/op2aws/opaws"
-	"os"
 
-	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/spf13/cobra"
 )
 
-func runAwsCliCommand(vault, item, mfaArn, assumeRoleArn string, forceCache bool, export bool, awsAccessKeyFieldDefault, awsSecretAccessKeyFieldDefault string) {
-	opClient := awsvault.NewOnePasswordVault(&awsvault.CommandClientDefault{}, vault, item)
-	opClient.SetDefaults(awsAccessKeyFieldDefault, awsSecretAccessKeyFieldDefault, "TODO")
-
-	awsClient := opaws.New(opClient, &opaws.OpAwsDefaultInput{})
-	awsClient.UseMFA(mfaArn)
-	awsClient.AssumeRole(assumeRoleArn)
-
-	cacheClient := cache.New(&cache.AWSCredentialsCacheOsClientDefault{}, os.Getenv("HOME"))
-	cacheClient.GenerateFromOP(opClient)
-	cacheClient.GenerateFromOPAWS(awsClient)
-
-	var credentials *sts.Credentials
-	cacheCredentials, err := cacheClient.GetCache()
+func runAwsCliCommand(profile *opaws.OpProfile, forceCache bool, export bool) {
+	credentials, err := getCredentials(profile, forceCache)
 	handleError(err)
 
-	if cacheCredentials == nil || forceCache {
-		for i := 0; i < 1; i++ {
-			c, err := awsClient.GetCredentials()
-			handleError(err)
-
-			if c != nil {
-				credentials = c
-				break
-			}
-		}
-
-		err = cacheClient.Store(credentials)
-		handleError((err))
-	} else {
-		credentials = cacheCredentials
-	}
-
 	if export {
-		fmt.Println("AWS_ACCESS_KEY_ID=" + *credentials.AccessKeyId)
-		fmt.Println("AWS_SECRET_ACCESS_KEY=" + *credentials.SecretAccessKey)
-		fmt.Println("AWS_SESSION_TOKEN=" + *credentials.SessionToken)
+		for _, v := range getCredentialsEnvironment(credentials) {
+			fmt.Println(v)
+		}
 	} else {
 		credentialsByteString, err := json.Marshal(credentials)
 		handleError(err)
@@ -66,28 +34,56 @@ func runAwsCliCommand(vault, item, mfaArn, assumeRoleArn string, forceCache bool
 	}
 }
 
+// The flags, that are part of the profile and can't be used with --profile.
+var CLI_PROFILE_FLAGS = []string{"mfa", "assume-role", "mfa-session", "label-accesskey", "label-secret-accesskey", "preset", "profile-name"}
+
 func addAwsCliCmd() {
-	var mfaArn string
-	var assumeRoleArn string
+	var profile opaws.OpProfile
 	var forceCache bool
 	var export bool
-	var awsAccessKeyFieldDefault string
-	var awsSecretAccessKeyFieldDefault string
+	var profileName string
 
 	cmd := &cobra.Command{
-		Use:   config.COMMAND_CLI,
+		Use:   config.COMMAND_CLI + " [<vault> <item>]",
 		Short: "Functionality to use inside of the .aws/config file",
-		Long:  "This function can be used inside of the .aws/config file as profile:\n\n[profile nextunit]\n   credential_process = sh -c '\"" + config.COMMAND_ROOT + "\" \"cli-profile\" \"1password-vault\" \"1password-item\" \"-m\" \"mfa-arn\" \"-a\" \"assume-role-arn\"'",
-		Args:  cobra.ExactArgs(2),
+		Long:  "This function can be used inside of the .aws/config file as profile:\n\n[profile nextunit]\n   credential_process = " + config.COMMAND_ROOT + " " + config.COMMAND_CLI + " 1password-vault 1password-item -m mfa-arn -a assume-role-arn\n\nNames with spaces or special characters have to be quoted, on Windows with double quotes, e.g. \"my vault\"\n\nWith --profile the credentials of a profile of the .aws/config file are returned, also of profiles with an op2aws_source_profile.\n\nChained profiles use op2aws_source_profile, op2aws_role_arn and op2aws_mfa_serial instead of source_profile, role_arn and mfa_serial, so the AWS CLI calls the credential_process instead of resolving the chain and asking for the MFA code itself.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if profileName != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+
+		ValidArgsFunction: completeVaultItem,
 		Run: func(cmd *cobra.Command, args []string) {
-			runAwsCliCommand(args[0], args[1], mfaArn, assumeRoleArn, forceCache, export, awsAccessKeyFieldDefault, awsSecretAccessKeyFieldDefault)
+			if profileName != "" {
+				for _, flag := range CLI_PROFILE_FLAGS {
+					if cmd.Flags().Changed(flag) {
+						handleError(fmt.Errorf("The flag --%s can't be used together with --profile", flag))
+					}
+				}
+
+				runAwsCliCommand(loadProfile(profileName), forceCache, export)
+				return
+			}
+
+			profile.Vault = args[0]
+			profile.Item = args[1]
+			resolveProfile(&profile)
+			runAwsCliCommand(&profile, forceCache, export)
 		},
 	}
-	cmd.Flags().StringVarP(&mfaArn, "mfa", "m", "", "When using 1password MFA it is possible to use this flag to specify the MFA arn")
-	cmd.Flags().StringVarP(&assumeRoleArn, "assume-role", "a", "", "To assume a specific role when getting the credentials, it is possible to use this flat for adding the arn of the role")
+	cmd.Flags().StringVarP(&profile.MFA, "mfa", "m", "", "When using 1password MFA it is possible to use this flag to specify the MFA arn or auto to detect it")
+	cmd.Flags().StringVarP(&profile.AssumeRole, "assume-role", "a", "", "To assume a specific role when getting the credentials, it is possible to use this flat for adding the arn of the role")
+	cmd.Flags().BoolVar(&profile.MFASession, "mfa-session", false, "To assume the role with a cached MFA session, so that switching between roles of the same credentials only needs one MFA code. The trust policy of the role has to accept aws:MultiFactorAuthPresent")
 	cmd.Flags().BoolVarP(&forceCache, "force", "f", false, "To force the execution without using the cache")
 	cmd.Flags().BoolVarP(&export, "export", "e", false, "To get the export command. It can be used to run it via `export $(op2aws cli ... --export)`")
-	cmd.Flags().StringVarP(&awsAccessKeyFieldDefault, "label-accesskey", "k", awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT, "To override the label field name in 1password for the AWS_ACCESS_KEY_ID")
-	cmd.Flags().StringVarP(&awsSecretAccessKeyFieldDefault, "label-secret-accesskey", "s", awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT, "To override the label field name in 1password for the AWS_SECRET_ACCESS_KEY")
+	cmd.Flags().StringVarP(&profile.LabelAccessKey, "label-accesskey", "k", "", "To override the label field name in 1password for the AWS_ACCESS_KEY_ID (default \""+awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	cmd.Flags().StringVarP(&profile.LabelSecretAccessKey, "label-secret-accesskey", "s", "", "To override the label field name in 1password for the AWS_SECRET_ACCESS_KEY (default \""+awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	cmd.Flags().StringVar(&profile.Preset, "preset", "", "The preset of the settings file, that is used for the settings of the profile")
+	cmd.Flags().StringVar(&profile.Name, "profile-name", "", "The name of the profile of the .aws/config file, that uses this credential_process. It selects the settings of the profile and is recorded inside of the history")
+	cmd.Flags().StringVarP(&profileName, "profile", "p", "", "To use the profile of the .aws/config file instead of the vault, the item and the flags of the profile")
+	cmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	registerLabelCompletion(cmd)
 	rootCMD.AddCommand(cmd)
 }
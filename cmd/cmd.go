@@ -1,33 +1,94 @@
 package cmd
 
 import (
-	"fmt"
 	"nextunit/op2aws/config"
+	"nextunit/op2aws/logger"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
 
 var rootCMD *cobra.Command
 
+var (
+	verbose bool
+	debug   bool
+)
+
+// setupLogger writes the log to stderr and the log file.
+func setupLogger() {
+	level := logger.LEVEL_WARN
+	if envLevel := os.Getenv(config.ENV_LOG_LEVEL); envLevel != "" {
+		l, err := logger.ParseLevel(envLevel)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		level = l
+	}
+
+	if verbose {
+		level = logger.LEVEL_INFO
+	}
+	if debug {
+		level = logger.LEVEL_DEBUG
+	}
+
+	logFilePath := config.GetLogFilePath()
+	var file *os.File
+	if err := os.MkdirAll(filepath.Dir(logFilePath), 0700); err == nil {
+		file, _ = os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	}
+
+	if file == nil {
+		logger.SetDefault(logger.New(level, os.Stderr, nil))
+		return
+	}
+
+	logger.SetDefault(logger.New(level, os.Stderr, file))
+}
+
 func init() {
 	rootCMD = &cobra.Command{
 		Use:   config.COMMAND_ROOT + " [command]",
 		Short: config.COMMAND_ROOT + " is a tool to provide AwS credentials from 1password",
 		Long:  config.COMMAND_ROOT + " is a tool that provides AWS credentials for the CLI or other AWS SDKs/CLIs by using 1password and generate credentials for MFA / Assume Roles in AWS or just by storing the hardcoded credentials in 1password",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			setupLogger()
+		},
 	}
+	rootCMD.PersistentFlags().BoolVar(&verbose, "verbose", false, "To log the stages of the command to stderr and "+config.GetLogFilePath())
+	rootCMD.PersistentFlags().BoolVar(&debug, "debug", false, "To log the details of the stages, e.g. the 1password calls with their timings")
 
 	addAwsCliCmd()
 	addAwsConfigCmd()
+	addShellCmd()
+	addServeCmd()
+	addConsoleCmd()
+	addLoginCmd()
+	addLogoutCmd()
+	addAgentCmd()
+	addVerifyCmd()
+	addRotateCmd()
+	addAuditCmd()
+	addItemCmd()
+	addMfaCmd()
+	addHistoryCmd()
+	addCompletionCmd()
+	addSettingsCmd()
+	addUseCmd()
 }
 
 func Execute() {
-	rootCMD.Execute()
+	// The commands exit with handleError, so only a wrong usage is returned.
+	if err := rootCMD.Execute(); err != nil {
+		os.Exit(EXIT_USAGE)
+	}
 }
 
 func handleError(err error) {
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(getExitCode(err))
 	}
 }
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	SHELL_BASH       = "bash"
+	SHELL_ZSH        = "zsh"
+	SHELL_FISH       = "fish"
+	SHELL_POWERSHELL = "powershell"
+
+	PROFILE_ALL = "all"
+)
+
+// COMPLETION_TIMEOUT limits every 1password call of a completion.
+var COMPLETION_TIMEOUT = 2 * time.Second
+
+func getCompletionClient() awsvault.CommandInterface {
+	return &awsvault.CommandClientTimeout{Timeout: COMPLETION_TIMEOUT}
+}
+
+// filterCompletions returns the values with the prefix, that have not been used as argument yet.
+func filterCompletions(values []string, args []string, toComplete string) []string {
+	used := map[string]bool{}
+	for _, arg := range args {
+		used[arg] = true
+	}
+
+	completions := []string{}
+	for _, value := range values {
+		if !used[value] && strings.HasPrefix(value, toComplete) {
+			completions = append(completions, value)
+		}
+	}
+
+	return completions
+}
+
+func getProfileNames() []string {
+	profiles, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH).GetProfiles()
+	if err != nil {
+		return []string{}
+	}
+
+	names := []string{}
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+	}
+
+	return names
+}
+
+// completeProfile completes the name of one op2aws profile of the config file.
+func completeProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(getProfileNames(), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles completes any number of op2aws profiles of the config file.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return filterCompletions(getProfileNames(), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeCredentialsProfile completes the name of one profile, that op2aws has written into the credentials file.
+func completeCredentialsProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_CREDENTIALS_FILE_PATH).GetCredentialsProfiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return filterCompletions(names, args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeVerifyProfile(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(append(getProfileNames(), PROFILE_ALL), args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeVault(toComplete string) ([]string, cobra.ShellCompDirective) {
+	vaults, err := awsvault.GetVaults(getCompletionClient())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return filterCompletions(getNameList(vaults), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeVaultItem completes the vault as first and the item inside of the vault as second argument.
+func completeVaultItem(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeVault(toComplete)
+	case 1:
+		items, err := awsvault.GetItems(getCompletionClient(), args[0])
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		return filterCompletions(getNameList(items), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func completeVaultFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeVault(toComplete)
+}
+
+// completeLabel completes the field labels of the item, which is given by the vault and item arguments.
+func completeLabel(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) < 2 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	entries, err := awsvault.GetEntries(getCompletionClient(), args[0], args[1])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return filterCompletions(getNameList(entries), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerLabelCompletion completes the -k and -s flags of commands with the vault and item as arguments.
+func registerLabelCompletion(cmd *cobra.Command) {
+	cmd.RegisterFlagCompletionFunc("label-accesskey", completeLabel)
+	cmd.RegisterFlagCompletionFunc("label-secret-accesskey", completeLabel)
+}
+
+func runCompletionCommand(shell string) {
+	var err error
+
+	switch shell {
+	case SHELL_BASH:
+		err = rootCMD.GenBashCompletionV2(os.Stdout, true)
+	case SHELL_ZSH:
+		err = rootCMD.GenZshCompletion(os.Stdout)
+	case SHELL_FISH:
+		err = rootCMD.GenFishCompletion(os.Stdout, true)
+	case SHELL_POWERSHELL:
+		err = rootCMD.GenPowerShellCompletionWithDesc(os.Stdout)
+	default:
+		err = fmt.Errorf("The shell %s is not supported, use %s, %s, %s or %s", shell, SHELL_BASH, SHELL_ZSH, SHELL_FISH, SHELL_POWERSHELL)
+	}
+
+	handleError(err)
+}
+
+func addCompletionCmd() {
+	// Replaces the default command of cobra to document the installation.
+	rootCMD.CompletionOptions.DisableDefaultCmd = true
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_COMPLETION + " " + strings.Join([]string{SHELL_BASH, SHELL_ZSH, SHELL_FISH, SHELL_POWERSHELL}, "|"),
+		Short: "Generates the completion script for the shell",
+		Long: "Generates the completion script for the shell. Profiles, vaults, items and field labels are completed, 1password is asked with a timeout of " + COMPLETION_TIMEOUT.String() + ".\n\n" +
+			"bash:       source <(" + config.COMMAND_ROOT + " " + config.COMMAND_COMPLETION + " bash)\n" +
+			"zsh:        " + config.COMMAND_ROOT + " " + config.COMMAND_COMPLETION + " zsh > \"${fpath[1]}/_" + config.COMMAND_ROOT + "\"\n" +
+			"fish:       " + config.COMMAND_ROOT + " " + config.COMMAND_COMPLETION + " fish > ~/.config/fish/completions/" + config.COMMAND_ROOT + ".fish\n" +
+			"powershell: " + config.COMMAND_ROOT + " " + config.COMMAND_COMPLETION + " powershell | Out-String | Invoke-Expression",
+		ValidArgs: []string{SHELL_BASH, SHELL_ZSH, SHELL_FISH, SHELL_POWERSHELL},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCompletionCommand(args[0])
+		},
+	}
+	rootCMD.AddCommand(cmd)
+}
@@ -1,17 +1,32 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/logger"
 	"nextunit/op2aws/opaws"
 	"os"
+	"regexp"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
+const (
+	MFA_OPTION_AUTO    = "Detect the MFA device at runtime (" + opaws.MFA_AUTO + ")"
+	MFA_OPTION_MANUAL  = "Enter the MFA arn manually"
+	PRESET_OPTION_NONE = "No preset"
+	IMPORT_OPTION_SKIP = "Skip the source profile"
+)
+
+var MFA_PATTERN = regexp.MustCompile(`^(arn:aws[a-z-]*:iam::[0-9]{12}:mfa/.+|[A-Za-z0-9]{9,256})$`)
+
 func getNameList[T awsvault.OpInterface](list []T) []string {
 	var nameList []string = []string{}
 
@@ -22,93 +37,315 @@ func getNameList[T awsvault.OpInterface](list []T) []string {
 	return nameList
 }
 
-func runAwsConfigCommand(configPath string) {
-	if !term.IsTerminal(int(syscall.Stdin)) {
-		handleError(fmt.Errorf("This functionality is not available inside of a non interactive terminal"))
+// validateMFA accepts the ARN of a virtual MFA device, the serial number of a hardware MFA device or auto.
+func validateMFA(value interface{}) error {
+	if mfa, ok := value.(string); ok && (mfa == opaws.MFA_AUTO || MFA_PATTERN.MatchString(mfa)) {
+		return nil
 	}
 
-	commandClient := &awsvault.CommandClientDefault{}
+	return fmt.Errorf("The value is neither the ARN of a MFA device like arn:aws:iam::123456789012:mfa/user nor %s", opaws.MFA_AUTO)
+}
 
-	var profileName string
-	var vaultName string
-	var itemName string
-	var awsAccessKeyFieldDefault = awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT
-	var awsSecretAccessKeyFieldDefault = awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT
-	var assumeRole string
+// getSelectDefault returns the value, when it is one of the options of the select.
+func getSelectDefault(options []string, value string) interface{} {
+	for _, option := range options {
+		if option == value {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// askMFA offers the MFA devices of the IAM user inside of the vault or asks for the MFA.
+func askMFA(profile *opaws.OpProfile) string {
 	var mfa string
 
-	vaultList, err := awsvault.GetVaults(commandClient)
-	handleError(err)
+	serialNumbers, err := opaws.New(getVaultClient(profile), &opaws.OpAwsDefaultInput{}).GetMFADevices()
+	if err != nil {
+		logger.Warn("The MFA devices of the IAM user could not be listed", "error", err)
+	}
+
+	if err == nil && len(serialNumbers) != 0 {
+		options := append(serialNumbers, MFA_OPTION_AUTO, MFA_OPTION_MANUAL)
+		defaultMFA := profile.MFA
+		if defaultMFA == opaws.MFA_AUTO {
+			defaultMFA = MFA_OPTION_AUTO
+		}
+
+		survey.AskOne(&survey.Select{
+			Message: "Select the MFA device:",
+			Options: options,
+			Default: getSelectDefault(options, defaultMFA),
+		}, &mfa, survey.WithValidator(survey.Required))
+
+		switch mfa {
+		case MFA_OPTION_AUTO:
+			return opaws.MFA_AUTO
+		case MFA_OPTION_MANUAL:
+		default:
+			return mfa
+		}
+	}
 
 	survey.AskOne(&survey.Input{
-		Message: "Enter new profile name:",
-	}, &profileName, survey.WithValidator(survey.MinLength(1)))
+		Message: fmt.Sprintf("Enter the MFA arn you'd like to assume or %s to detect it at runtime:", opaws.MFA_AUTO),
+		Default: profile.MFA,
+	}, &mfa, survey.WithValidator(validateMFA))
 
-	survey.AskOne(&survey.Select{
-		Message: "Select credentials vault:",
-		Options: getNameList(vaultList),
-	}, &vaultName, survey.WithValidator(survey.Required))
+	return mfa
+}
 
-	itemList, err := awsvault.GetItems(commandClient, vaultName)
-	handleError(err)
+// askPreset offers the presets of the settings file. Without presets nothing is asked.
+func askPreset(current string) string {
+	file, err := config.LoadSettingsFile(config.GetSettingsFilePath())
+	if err != nil {
+		logger.Warn("The presets could not be read", "error", err)
+		return current
+	}
 
+	presets := file.GetPresetNames()
+	if len(presets) == 0 {
+		return ""
+	}
+
+	var preset string
+	options := append([]string{PRESET_OPTION_NONE}, presets...)
 	survey.AskOne(&survey.Select{
-		Message: "Select credentials item:",
-		Options: getNameList(itemList),
-	}, &itemName, survey.WithValidator(survey.Required))
+		Message: "Select the preset of the settings:",
+		Options: options,
+		Default: getSelectDefault(options, current),
+	}, &preset)
+
+	if preset == PRESET_OPTION_NONE {
+		return ""
+	}
 
-	assumeRoleRequired := false
+	return preset
+}
+
+func checkTerminal() {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		handleError(fmt.Errorf("This functionality is not available inside of a non interactive terminal"))
+	}
+}
+
+// askSourceProfile offers to base the profile on an existing profile.
+func askSourceProfile(current *opaws.OpProfile) *opaws.OpProfile {
+	profiles, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH).GetProfiles()
+	if err != nil {
+		return nil
+	}
+
+	sources := map[string]*opaws.OpProfile{}
+	names := []string{}
+	for _, profile := range profiles {
+		if profile.Name != current.Name {
+			sources[profile.Name] = profile
+			names = append(names, profile.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	basedOnProfile := current.SourceProfile != ""
 	survey.AskOne(&survey.Confirm{
-		Message: "Do you like to assume a specific role?",
-	}, &assumeRoleRequired)
+		Message: "Do you like to base the profile on an existing profile? (The role is assumed with the credentials of the source profile)",
+		Default: basedOnProfile,
+	}, &basedOnProfile)
 
-	if assumeRoleRequired {
-		survey.AskOne(&survey.Input{
-			Message: "Enter the role arn you'd like to assume:",
-		}, &assumeRole, survey.WithValidator(survey.MinLength(20)))
+	if !basedOnProfile {
+		return nil
 	}
 
-	mfaRequired := false
+	var sourceName string
+	survey.AskOne(&survey.Select{
+		Message: "Select the source profile:",
+		Options: names,
+		Default: getSelectDefault(names, current.SourceProfile),
+	}, &sourceName, survey.WithValidator(survey.Required))
+
+	return sources[sourceName]
+}
+
+// askChainedProfile asks for the role, that is assumed with the credentials of the source profile, and its MFA.
+func askChainedProfile(profileName string, source, current *opaws.OpProfile) *opaws.OpProfile {
+	var assumeRole string
+	survey.AskOne(&survey.Input{
+		Message: "Enter the role arn you'd like to assume:",
+		Default: current.AssumeRole,
+	}, &assumeRole, survey.WithValidator(survey.MinLength(20)))
+
+	mfa := ""
+	mfaRequired := current.SourceProfile != "" && current.MFA != ""
 	survey.AskOne(&survey.Confirm{
-		Message: "Do you like to configure MFA?",
+		Message: "Do you like to configure MFA? (It is used for the source profile, when it has none)",
+		Default: mfaRequired,
 	}, &mfaRequired)
 
 	if mfaRequired {
+		mfaProfile := &opaws.OpProfile{
+			Name:                 source.Name,
+			Vault:                source.Vault,
+			Item:                 source.Item,
+			MFA:                  current.MFA,
+			LabelAccessKey:       source.LabelAccessKey,
+			LabelSecretAccessKey: source.LabelSecretAccessKey,
+		}
+		resolveProfile(mfaProfile)
+		mfa = askMFA(mfaProfile)
+	}
+
+	return &opaws.OpProfile{
+		Name:                 profileName,
+		Vault:                source.Vault,
+		Item:                 source.Item,
+		AssumeRole:           assumeRole,
+		MFA:                  mfa,
+		LabelAccessKey:       source.LabelAccessKey,
+		LabelSecretAccessKey: source.LabelSecretAccessKey,
+		SourceProfile:        source.Name,
+		Source:               source,
+	}
+}
+
+// askProfile asks for the values of the profile with its current values as defaults.
+func askProfile(current *opaws.OpProfile, isNew bool) *opaws.OpProfile {
+	commandClient := &awsvault.CommandClientDefault{}
+
+	profileName := current.Name
+	vaultName := current.Vault
+	itemName := current.Item
+	var awsAccessKeyFieldDefault = awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT
+	var awsSecretAccessKeyFieldDefault = awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT
+	assumeRole := current.AssumeRole
+	mfa := current.MFA
+
+	if isNew {
 		survey.AskOne(&survey.Input{
-			Message: "Enter the MFA arn you'd like to assume:",
-		}, &mfa, survey.WithValidator(survey.MinLength(9)))
+			Message: "Enter new profile name:",
+		}, &profileName, survey.WithValidator(survey.MinLength(1)))
+	}
+
+	if !isNew || vaultName == "" {
+		if source := askSourceProfile(current); source != nil {
+			return askChainedProfile(profileName, source, current)
+		}
 	}
 
-	changeDefaultLabelNames := false
+	if !isNew || vaultName == "" {
+		vaultList, err := awsvault.GetVaults(commandClient)
+		handleError(err)
+
+		options := getNameList(vaultList)
+		survey.AskOne(&survey.Select{
+			Message: "Select credentials vault:",
+			Options: options,
+			Default: getSelectDefault(options, current.Vault),
+		}, &vaultName, survey.WithValidator(survey.Required))
+	}
+
+	if !isNew || itemName == "" {
+		itemList, err := awsvault.GetItems(commandClient, vaultName)
+		handleError(err)
+
+		options := getNameList(itemList)
+		survey.AskOne(&survey.Select{
+			Message: "Select credentials item:",
+			Options: options,
+			Default: getSelectDefault(options, current.Item),
+		}, &itemName, survey.WithValidator(survey.Required))
+	}
+
+	changeDefaultLabelNames := current.LabelAccessKey != "" || current.LabelSecretAccessKey != ""
 	survey.AskOne(&survey.Confirm{
 		Message: fmt.Sprintf("Do you like to change the default label names for the AWS credentials in 1password? (%s, %s)", awsAccessKeyFieldDefault, awsSecretAccessKeyFieldDefault),
+		Default: changeDefaultLabelNames,
 	}, &changeDefaultLabelNames)
 
 	if changeDefaultLabelNames {
 		entries, err := awsvault.GetEntries(commandClient, vaultName, itemName)
 		handleError(err)
 
+		options := getNameList(entries)
 		survey.AskOne(&survey.Select{
 			Message: "Select the label name for the AWS_ACCESS_KEY_ID:",
-			Options: getNameList(entries),
+			Options: options,
+			Default: getSelectDefault(options, current.LabelAccessKey),
 		}, &awsAccessKeyFieldDefault, survey.WithValidator(survey.Required))
 
 		survey.AskOne(&survey.Select{
 			Message: "Select the label name for the AWS_SECRET_ACCESS_KEY:",
-			Options: getNameList(entries),
+			Options: options,
+			Default: getSelectDefault(options, current.LabelSecretAccessKey),
 		}, &awsSecretAccessKeyFieldDefault, survey.WithValidator(survey.Required))
 	}
 
+	assumeRoleRequired := current.AssumeRole != ""
+	survey.AskOne(&survey.Confirm{
+		Message: "Do you like to assume a specific role?",
+		Default: assumeRoleRequired,
+	}, &assumeRoleRequired)
+
+	if assumeRoleRequired {
+		survey.AskOne(&survey.Input{
+			Message: "Enter the role arn you'd like to assume:",
+			Default: current.AssumeRole,
+		}, &assumeRole, survey.WithValidator(survey.MinLength(20)))
+	} else {
+		assumeRole = ""
+	}
+
+	mfaRequired := current.MFA != ""
+	survey.AskOne(&survey.Confirm{
+		Message: "Do you like to configure MFA?",
+		Default: mfaRequired,
+	}, &mfaRequired)
+
+	if mfaRequired {
+		mfa = askMFA(&opaws.OpProfile{
+			Vault:                vaultName,
+			Item:                 itemName,
+			MFA:                  current.MFA,
+			LabelAccessKey:       awsAccessKeyFieldDefault,
+			LabelSecretAccessKey: awsSecretAccessKeyFieldDefault,
+		})
+	} else {
+		mfa = ""
+	}
+
+	mfaSession := false
+	if assumeRoleRequired && mfaRequired {
+		mfaSession = current.MFASession
+		survey.AskOne(&survey.Confirm{
+			Message: "Do you like to reuse one MFA session for all roles of these credentials? (The trust policy of the role has to accept aws:MultiFactorAuthPresent)",
+			Default: mfaSession,
+		}, &mfaSession)
+	}
+
+	return &opaws.OpProfile{
+		Name:                 profileName,
+		Vault:                vaultName,
+		Item:                 itemName,
+		AssumeRole:           assumeRole,
+		MFA:                  mfa,
+		LabelAccessKey:       awsAccessKeyFieldDefault,
+		LabelSecretAccessKey: awsSecretAccessKeyFieldDefault,
+		MFASession:           mfaSession,
+		Preset:               askPreset(current.Preset),
+	}
+}
+
+// runAwsConfigCommand asks for the profile. The vault and the item are only asked for, when they are empty.
+func runAwsConfigCommand(configPath, vaultName, itemName string) {
+	checkTerminal()
+
+	profile := askProfile(&opaws.OpProfile{Vault: vaultName, Item: itemName}, true)
+
 	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH)
-	body := opaws.GetProfileBody(
-		profileName,
-		vaultName,
-		itemName,
-		assumeRole,
-		mfa,
-		awsAccessKeyFieldDefault,
-		awsSecretAccessKeyFieldDefault,
-	)
+	body := profile.GetBody()
 
 	writeFile := false
 	survey.AskOne(&survey.Confirm{
@@ -119,19 +356,280 @@ func runAwsConfigCommand(configPath string) {
 		),
 	}, &writeFile)
 	if writeFile {
-		c.WriteProfile(body)
+		handleError(c.WriteProfile(body))
 		fmt.Println("Added to config file.")
 	}
 }
 
+func runConfigListCommand(output string) {
+	if output != OUTPUT_TABLE && output != OUTPUT_JSON {
+		handleError(fmt.Errorf("The output format %s is not supported, use %s or %s", output, OUTPUT_TABLE, OUTPUT_JSON))
+	}
+
+	profiles, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH).GetProfiles()
+	handleError(err)
+
+	if output == OUTPUT_JSON {
+		content, err := json.MarshalIndent(profiles, "", "  ")
+		handleError(err)
+		fmt.Println(string(content))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tSOURCE PROFILE\tVAULT\tITEM\tROLE\tMFA\tPRESET")
+	for _, p := range profiles {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Name,
+			formatOptionalValue(p.SourceProfile),
+			p.Vault,
+			p.Item,
+			formatOptionalValue(p.AssumeRole),
+			formatOptionalValue(p.MFA),
+			formatOptionalValue(p.Preset),
+		)
+	}
+	w.Flush()
+}
+
+func runConfigRemoveCommand(profileName string, yes bool) {
+	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH)
+
+	if !yes {
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			handleError(fmt.Errorf("Use --yes to remove the profile inside of a non interactive terminal"))
+		}
+
+		survey.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("Do you like to remove the profile %s from %s?", profileName, c.GetPath()),
+		}, &yes)
+		if !yes {
+			return
+		}
+	}
+
+	handleError(c.RemoveProfile(profileName))
+	fmt.Printf("Removed the profile %s from the config file.\n", profileName)
+}
+
+func runConfigEditCommand(profileName string) {
+	checkTerminal()
+
+	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH)
+	current, err := c.GetProfile(profileName)
+	handleError(err)
+
+	profile := askProfile(current, false)
+
+	writeFile := false
+	survey.AskOne(&survey.Confirm{
+		Message: fmt.Sprintf(
+			"Do you like to change the profile to:%s\n\nWrite now to %s?",
+			profile.GetBody(),
+			c.GetPath(),
+		),
+	}, &writeFile)
+	if writeFile {
+		handleError(c.UpdateProfile(profile))
+		fmt.Println("Changed the profile inside of the config file.")
+	}
+}
+
+func runConfigMigrateCommand(dryRun bool) {
+	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH)
+
+	var migrations []opaws.ProfileMigration
+	var err error
+	if dryRun {
+		migrations, err = c.GetMigrations()
+	} else {
+		migrations, err = c.MigrateProfiles()
+	}
+	handleError(err)
+
+	if len(migrations) == 0 {
+		fmt.Println("There are no profiles to migrate.")
+		return
+	}
+
+	for _, migration := range migrations {
+		fmt.Printf("[profile %s]\n- credential_process = %s\n+ credential_process = %s\n\n", migration.Name, migration.Old, migration.New)
+	}
+
+	if dryRun {
+		fmt.Printf("Would migrate %d profile(s).\n", len(migrations))
+	} else {
+		fmt.Printf("Migrated %d profile(s) inside of %s.\n", len(migrations), c.GetPath())
+	}
+}
+
+// askImportItems asks for the items of the source profiles, that are not inside of the mapping file.
+func askImportItems(sourceProfiles []string, items map[string]opaws.ImportItem) {
+	interactive := term.IsTerminal(int(syscall.Stdin))
+	commandClient := &awsvault.CommandClientDefault{}
+
+	for _, name := range sourceProfiles {
+		if _, ok := items[name]; ok {
+			continue
+		}
+
+		if !interactive {
+			logger.Warn("The source profile is not inside of the mapping file and is skipped", "source_profile", name)
+			continue
+		}
+
+		vaultList, err := awsvault.GetVaults(commandClient)
+		handleError(err)
+
+		var vaultName string
+		survey.AskOne(&survey.Select{
+			Message: fmt.Sprintf("Select the vault with the credentials of the source profile %s:", name),
+			Options: append([]string{IMPORT_OPTION_SKIP}, getNameList(vaultList)...),
+		}, &vaultName, survey.WithValidator(survey.Required))
+		if vaultName == IMPORT_OPTION_SKIP {
+			continue
+		}
+
+		itemList, err := awsvault.GetItems(commandClient, vaultName)
+		handleError(err)
+
+		var itemName string
+		survey.AskOne(&survey.Select{
+			Message: fmt.Sprintf("Select the item with the credentials of the source profile %s:", name),
+			Options: getNameList(itemList),
+		}, &itemName, survey.WithValidator(survey.Required))
+
+		items[name] = opaws.ImportItem{Vault: vaultName, Item: itemName}
+	}
+}
+
+func runConfigImportCommand(from, mappingPath string, write bool) {
+	if from != opaws.IMPORT_FROM_AWS_VAULT {
+		handleError(fmt.Errorf("The import from %s is not supported, use %s", from, strings.Join(opaws.IMPORT_SOURCES, ", ")))
+	}
+
+	items := map[string]opaws.ImportItem{}
+	if mappingPath != "" {
+		content, err := os.ReadFile(mappingPath)
+		handleError(err)
+
+		items, err = opaws.ParseImportMapping(content)
+		if err != nil {
+			handleError(fmt.Errorf("Invalid mapping file %s: %w", mappingPath, err))
+		}
+	}
+
+	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH)
+	sourceProfiles, err := c.GetAwsVaultSourceProfiles()
+	handleError(err)
+	askImportItems(sourceProfiles, items)
+
+	var imports []opaws.ProfileImport
+	if write {
+		imports, err = c.ImportAwsVault(items)
+	} else {
+		imports, err = c.GetAwsVaultImports(items)
+	}
+	handleError(err)
+
+	if len(imports) == 0 {
+		fmt.Println("There are no profiles to import.")
+		return
+	}
+
+	for _, profileImport := range imports {
+		fmt.Printf("%s\n\n", profileImport.GetDiff())
+	}
+
+	if write {
+		fmt.Printf("Imported %d profile(s) into %s.\n", len(imports), c.GetPath())
+	} else {
+		fmt.Printf("Would import %d profile(s). Use --write to change %s.\n", len(imports), c.GetPath())
+	}
+}
+
 func addAwsConfigCmd() {
+	var output string
+	var yes bool
+	var dryRun bool
+	var from string
+	var mappingPath string
+	var write bool
+
 	cmd := &cobra.Command{
-		Use:   "config",
+		Use:   config.COMMAND_CONFIG,
 		Short: "Functionality to administrate the .aws/config file",
-		Long:  "TODO",
+		Long:  "Without a subcommand a wizard adds a new profile, that uses " + config.COMMAND_ROOT + " as credential_process, to the .aws/config file.\nA profile based on an existing profile is written with op2aws_source_profile, op2aws_role_arn and op2aws_mfa_serial, which only " + config.COMMAND_ROOT + " reads, so the AWS CLI doesn't resolve the chain itself.",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			runAwsConfigCommand(fmt.Sprintf("%s/.aws/config", os.Getenv("HOME")))
+			runAwsConfigCommand(opaws.AWS_FILE_PATH, "", "")
 		},
 	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the profiles of the .aws/config file, that use " + config.COMMAND_ROOT + " as credential_process",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigListCommand(output)
+		},
+	}
+	listCmd.Flags().StringVarP(&output, "output", "o", OUTPUT_TABLE, "The output format: table or json")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <profile>",
+		Short: "Removes the section of the profile from the .aws/config file",
+		Long:  "Removes the section of the profile with all of its keys from the .aws/config file. Only profiles, that use " + config.COMMAND_ROOT + " as credential_process, are removed.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigRemoveCommand(args[0], yes)
+		},
+	}
+	removeCmd.Flags().BoolVarP(&yes, "yes", "y", false, "To remove the profile without asking for confirmation")
+
+	editCmd := &cobra.Command{
+		Use:   "edit <profile>",
+		Short: "Changes the profile with the wizard, that is prefilled with its current values",
+		Long:  "Changes the credential_process of the profile with the wizard, that is prefilled with its current values. The other keys of the profile, e.g. the region, are kept.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigEditCommand(args[0])
+		},
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrites the profiles, that use sh -c as credential_process, into the format without a shell",
+		Long:  "Older versions of " + config.COMMAND_ROOT + " wrapped the credential_process in sh -c '...', which doesn't work on Windows and with vault or item names containing quotes or $.\nThe profiles are rewritten with every argument quoted the way the AWS CLI splits the command line. The arguments are kept as they are.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigMigrateCommand(dryRun)
+		},
+	}
+	migrateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "To only show the changes without writing them")
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Imports the profiles of aws-vault with the credentials inside of 1password",
+		Long:  "Maps the source profiles of aws-vault onto items inside of 1password, either interactively or with a mapping file, and rewrites the profiles into profiles of " + config.COMMAND_ROOT + ".\nRoles of source profiles assume the role with their MFA, roles of other roles are chained with op2aws_source_profile.\nThe changes are only shown, until --write is used.\n\nThe mapping file maps the names of the source profiles onto the items:\n\ncompany:\n  vault: Employee\n  item: AWS company",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigImportCommand(from, mappingPath, write)
+		},
+	}
+	importCmd.Flags().StringVar(&from, "from", opaws.IMPORT_FROM_AWS_VAULT, "The tool of the profiles: "+strings.Join(opaws.IMPORT_SOURCES, ", "))
+	importCmd.Flags().StringVar(&mappingPath, "mapping", "", "The YAML file, that maps the source profiles onto the vaults and items")
+	importCmd.Flags().BoolVar(&write, "write", false, "To write the changes into the config file")
+	importCmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return filterCompletions(opaws.IMPORT_SOURCES, nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	cmd.AddCommand(listCmd, removeCmd, editCmd, migrateCmd, importCmd)
 	rootCMD.AddCommand(cmd)
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+func runConsoleCommand(profileName, destination string, duration time.Duration, forceCache, open bool) {
+	profile := loadProfile(profileName)
+
+	if profile.AssumeRole == "" {
+		handleError(fmt.Errorf("The profile %s is not assuming a role. The AWS console sign-in is only possible with the credentials of an assumed role, not with session tokens", profileName))
+	}
+
+	credentials, err := getCredentials(profile, forceCache)
+	handleError(err)
+
+	consoleUrl, err := opaws.GetConsoleUrl(opaws.FEDERATION_ENDPOINT, credentials, destination, duration)
+	handleError(err)
+
+	if open {
+		handleError(openBrowser(consoleUrl))
+		return
+	}
+
+	fmt.Println(consoleUrl)
+}
+
+func addConsoleCmd() {
+	var destination string
+	var duration time.Duration
+	var forceCache bool
+	var open bool
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_CONSOLE + " <profile>",
+		Short: "Generates a sign-in URL for the AWS console",
+		Long:  "Generates a sign-in URL for the AWS console with the credentials of an " + config.COMMAND_ROOT + " profile from the .aws/config file.\nThe profile has to assume a role, since the AWS federation endpoint doesn't accept session tokens.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConsoleCommand(args[0], destination, duration, forceCache, open)
+		},
+	}
+	cmd.Flags().StringVarP(&destination, "destination", "d", "", "The service path inside of the AWS console to open, e.g. ec2/home")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "The duration of the console session between 15m and 12h. AWS uses 1h, when it is not set")
+	cmd.Flags().BoolVarP(&forceCache, "force", "f", false, "To force the execution without using the cache")
+	cmd.Flags().BoolVarP(&open, "open", "o", false, "To open the URL inside of the browser instead of printing it")
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"errors"
+	"nextunit/op2aws/agent"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/cache"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/history"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/opaws"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// getCredentials asks the agent for the credentials and generates them without a running agent.
+func getCredentials(profile *opaws.OpProfile, forceCache bool) (*sts.Credentials, error) {
+	credentials, source, err := agent.NewClient(config.GetAgentSocketPath()).GetCredentials(profile, forceCache)
+	if !errors.Is(err, agent.ErrAgentUnavailable) {
+		if err == nil {
+			logger.Info("Got the credentials from the agent", "socket", config.GetAgentSocketPath(), "source", source)
+			recordHistory(profile, credentials, source)
+		}
+		return credentials, err
+	}
+	logger.Debug("The agent is not running", "socket", config.GetAgentSocketPath())
+
+	credentials, source, err = getCredentialsWithSource(profile, forceCache)
+	if err != nil {
+		return nil, err
+	}
+	recordHistory(profile, credentials, source)
+
+	return credentials, nil
+}
+
+// recordHistory appends the issuance to the history. Errors are only logged.
+func recordHistory(profile *opaws.OpProfile, credentials *sts.Credentials, source string) {
+	mfa := profile.MFA
+	if mfa == opaws.MFA_AUTO {
+		if detected := getCacheClient(profile).PeekMFA(); detected != "" {
+			mfa = detected
+		}
+	}
+
+	parentPid, parentProcess := history.GetParent()
+	err := history.Append(config.GetHistoryFilePath(), history.Record{
+		Time:              time.Now(),
+		Profile:           profile.Name,
+		Vault:             profile.Vault,
+		Item:              profile.Item,
+		RoleArn:           profile.AssumeRole,
+		MFA:               mfa,
+		Source:            source,
+		Expiration:        credentials.Expiration,
+		AccessKeyIdPrefix: history.GetAccessKeyIdPrefix(aws.StringValue(credentials.AccessKeyId)),
+		ParentPid:         parentPid,
+		ParentProcess:     parentProcess,
+	})
+	if err != nil {
+		logger.Error("Unable to record the credentials inside of the history", "path", config.GetHistoryFilePath(), "error", err)
+	}
+}
+
+func getVaultClient(profile *opaws.OpProfile) *awsvault.OnePassword {
+	opClient := awsvault.NewOnePasswordVault(&awsvault.CommandClientDefault{}, profile.Vault, profile.Item)
+	opClient.SetDefaults(profile.LabelAccessKey, profile.LabelSecretAccessKey, "TODO")
+
+	return opClient
+}
+
+func getCachedCredentials(profile *opaws.OpProfile, forceCache bool) (*sts.Credentials, error) {
+	credentials, _, err := getCredentialsWithSource(profile, forceCache)
+	return credentials, err
+}
+
+// getCacheClient returns the cache of the credentials of the profile.
+func getCacheClient(profile *opaws.OpProfile) *cache.AWSCredentialsCacheClient {
+	cacheDir := profile.CacheDir
+	if cacheDir == "" {
+		cacheDir = os.Getenv("HOME")
+	}
+
+	cacheClient := cache.New(&cache.AWSCredentialsCacheOsClientDefault{}, cacheDir)
+	cacheClient.StateDir(config.GetStateDir())
+	cacheClient.Vault(profile.Vault)
+	cacheClient.Item(profile.Item)
+	cacheClient.MFA(profile.MFA)
+	cacheClient.AssumeRole(profile.AssumeRole)
+	cacheClient.Settings(profile.LabelAccessKey, profile.LabelSecretAccessKey, profile.SessionName, profile.Region, profile.Duration.String())
+	if profile.Source != nil {
+		cacheClient.AssumeRole(profile.SourceProfile + ">" + profile.AssumeRole)
+	}
+
+	return cacheClient
+}
+
+// getCredentialsWithSource returns the credentials and whether they are from the cache or generated with STS.
+func getCredentialsWithSource(profile *opaws.OpProfile, forceCache bool) (*sts.Credentials, string, error) {
+	opClient := getVaultClient(profile)
+
+	awsClient := opaws.New(opClient, &opaws.OpAwsDefaultInput{})
+	awsClient.UseMFA(profile.MFA)
+	awsClient.AssumeRole(profile.AssumeRole)
+	awsClient.UseSessionName(profile.SessionName)
+	awsClient.UseRegion(profile.Region)
+	awsClient.UseDuration(profile.Duration)
+
+	cacheClient := getCacheClient(profile)
+	lock, err := cacheClient.Lock()
+	if err != nil {
+		return nil, "", err
+	}
+	defer lock.Unlock()
+
+	cacheCredentials, err := cacheClient.GetCache()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cacheCredentials != nil && !forceCache {
+		return cacheCredentials, history.SOURCE_CACHE, nil
+	}
+	if cacheCredentials != nil {
+		logger.Info("Ignoring the cache", "reason", "forced")
+	}
+
+	usedMFA := ""
+	if profile.Source != nil {
+		sourceCredentials, err := getSourceCredentials(profile, forceCache)
+		if err != nil {
+			return nil, "", err
+		}
+		awsClient.UseSourceCredentials(sourceCredentials)
+	} else if profile.MFASession && profile.AssumeRole != "" && profile.MFA != "" {
+		sessionCredentials, err := getMFASessionCredentials(profile)
+		if err != nil {
+			return nil, "", err
+		}
+		awsClient.UseSourceCredentials(sessionCredentials)
+		// The MFA session already proves the MFA, so no new code is needed.
+		awsClient.UseMFA("")
+		usedMFA = getCacheClient(profile.GetMFASessionProfile()).PeekMFA()
+	}
+
+	credentials, err := awsClient.GetCredentials()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if usedMFA == "" {
+		usedMFA = awsClient.GetUsedMFA()
+	}
+	err = cacheClient.Store(credentials, usedMFA)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return credentials, history.SOURCE_STS, nil
+}
+
+// getMFASessionCredentials returns the cached MFA session, that the roles of the credentials share.
+func getMFASessionCredentials(profile *opaws.OpProfile) (*sts.Credentials, error) {
+	return getCachedCredentials(profile.GetMFASessionProfile(), false)
+}
+
+// getSourceCredentials returns the credentials of the source profile with its cache.
+func getSourceCredentials(profile *opaws.OpProfile, forceCache bool) (*sts.Credentials, error) {
+	logger.Info("Getting the credentials of the source profile", "profile", profile.Name, "source_profile", profile.Source.Name)
+
+	credentials, _, err := getCredentialsWithSource(profile.Source, forceCache)
+	return credentials, err
+}
+
+func getCredentialsEnvironment(credentials *sts.Credentials) []string {
+	return []string{
+		"AWS_ACCESS_KEY_ID=" + *credentials.AccessKeyId,
+		"AWS_SECRET_ACCESS_KEY=" + *credentials.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + *credentials.SessionToken,
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/cache"
+	"nextunit/op2aws/opaws"
+)
+
+// The exit codes tell scripts why a command failed. 1 is any other error and 2 is a wrong usage.
+const (
+	EXIT_ERROR             = 1
+	EXIT_USAGE             = 2
+	EXIT_VAULT_UNAVAILABLE = 10
+	EXIT_PROMPT_CANCELLED  = 11
+	EXIT_NOT_FOUND         = 12
+	EXIT_MFA_REJECTED      = 20
+	EXIT_ROLE_TRUST        = 21
+	EXIT_ACCESS_DENIED     = 22
+	EXIT_NETWORK           = 23
+	EXIT_CONFIG_WRITE      = 30
+	EXIT_CACHE             = 31
+)
+
+// EXIT_CODES is checked in order.
+var EXIT_CODES = []struct {
+	Err  error
+	Code int
+}{
+	{awsvault.ErrVaultUnavailable, EXIT_VAULT_UNAVAILABLE},
+	{awsvault.ErrPromptCancelled, EXIT_PROMPT_CANCELLED},
+	{awsvault.ErrNotFound, EXIT_NOT_FOUND},
+	{opaws.ErrMFARejected, EXIT_MFA_REJECTED},
+	{opaws.ErrRoleTrust, EXIT_ROLE_TRUST},
+	{opaws.ErrAccessDenied, EXIT_ACCESS_DENIED},
+	{opaws.ErrNetwork, EXIT_NETWORK},
+	{opaws.ErrConfigWrite, EXIT_CONFIG_WRITE},
+	{cache.ErrCache, EXIT_CACHE},
+}
+
+func getExitCode(err error) int {
+	for _, exitCode := range EXIT_CODES {
+		if errors.Is(err, exitCode.Err) {
+			return exitCode.Code
+		}
+	}
+
+	return EXIT_ERROR
+}
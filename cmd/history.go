@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/history"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func formatHistoryTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatOptionalValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func printHistory(records []history.Record, output string) {
+	if output == OUTPUT_JSON {
+		content, err := json.MarshalIndent(records, "", "  ")
+		handleError(err)
+		fmt.Println(string(content))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tPROFILE\tROLE\tMFA\tSOURCE\tEXPIRATION\tACCESS KEY\tPARENT")
+	for _, r := range records {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s (%d)\n",
+			formatHistoryTime(&r.Time),
+			formatOptionalValue(r.Profile),
+			formatOptionalValue(r.RoleArn),
+			formatOptionalValue(r.MFA),
+			r.Source,
+			formatHistoryTime(r.Expiration),
+			formatOptionalValue(r.AccessKeyIdPrefix),
+			formatOptionalValue(r.ParentProcess),
+			r.ParentPid,
+		)
+	}
+	w.Flush()
+}
+
+func runHistoryCommand(profileName, since, until, output string) {
+	if output != OUTPUT_TABLE && output != OUTPUT_JSON {
+		handleError(fmt.Errorf("The output format %s is not supported, use %s or %s", output, OUTPUT_TABLE, OUTPUT_JSON))
+	}
+
+	now := time.Now()
+	sinceTime, err := history.ParseTime(since, now)
+	handleError(err)
+	untilTime, err := history.ParseUntil(until, now)
+	handleError(err)
+
+	records, err := history.ReadFile(config.GetHistoryFilePath(), history.Filter{
+		Profile: profileName,
+		Since:   sinceTime,
+		Until:   untilTime,
+	})
+	handleError(err)
+
+	printHistory(records, output)
+}
+
+func addHistoryCmd() {
+	var profileName string
+	var since string
+	var until string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_HISTORY,
+		Short: "Shows when which credentials have been issued",
+		Long:  "Shows the history of the issued credentials from " + config.GetHistoryFilePath() + ": the profile, the role, the MFA device, whether the credentials came from the cache, STS or the agent, their expiration, the prefix of the access key id and the process, that requested them.\nThe time range is set with RFC 3339 timestamps, dates like 2006-01-02 or durations before now like 12h or 7d.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHistoryCommand(profileName, since, until, output)
+		},
+	}
+	cmd.Flags().StringVarP(&profileName, "profile", "p", "", "To only show the credentials of the profile")
+	cmd.Flags().StringVar(&since, "since", "", "To only show the credentials issued after the time, e.g. 7d or 2006-01-02")
+	cmd.Flags().StringVar(&until, "until", "", "To only show the credentials issued before the time, e.g. 12h, 2006-01-02T15:04:05Z or 2006-01-02 for the whole day")
+	cmd.Flags().StringVarP(&output, "output", "o", OUTPUT_TABLE, "The output format: table or json")
+	cmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	rootCMD.AddCommand(cmd)
+}
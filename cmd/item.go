@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"os"
+	"syscall"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func getAccessKey(csvPath string) (string, string) {
+	if csvPath != "" {
+		file, err := os.Open(csvPath)
+		handleError(err)
+		defer file.Close()
+
+		accessKeyId, secretAccessKey, err := opaws.ParseAccessKeyCsv(file)
+		handleError(err)
+		return accessKeyId, secretAccessKey
+	}
+
+	var accessKeyId string
+	var secretAccessKey string
+
+	survey.AskOne(&survey.Input{
+		Message: "Enter the AWS_ACCESS_KEY_ID:",
+	}, &accessKeyId, survey.WithValidator(survey.MinLength(16)))
+
+	survey.AskOne(&survey.Password{
+		Message: "Enter the AWS_SECRET_ACCESS_KEY:",
+	}, &secretAccessKey, survey.WithValidator(survey.MinLength(1)))
+
+	return accessKeyId, secretAccessKey
+}
+
+func runItemCreateCommand(vaultName, title, csvPath string) {
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		handleError(fmt.Errorf("This functionality is not available inside of a non interactive terminal"))
+	}
+
+	commandClient := &awsvault.CommandClientDefault{}
+
+	if vaultName == "" {
+		vaultList, err := awsvault.GetVaults(commandClient)
+		handleError(err)
+
+		survey.AskOne(&survey.Select{
+			Message: "Select the vault for the new item:",
+			Options: getNameList(vaultList),
+		}, &vaultName, survey.WithValidator(survey.Required))
+	}
+
+	if title == "" {
+		survey.AskOne(&survey.Input{
+			Message: "Enter the name of the new item:",
+		}, &title, survey.WithValidator(survey.MinLength(1)))
+	}
+
+	// The profiles reference the item by its name, so it has to be unique inside of the vault.
+	itemList, err := awsvault.GetItems(commandClient, vaultName)
+	handleError(err)
+	for _, item := range itemList {
+		if item.Title == title {
+			handleError(fmt.Errorf("The item %s already exists inside of the vault %s", title, vaultName))
+		}
+	}
+
+	accessKeyId, secretAccessKey := getAccessKey(csvPath)
+	fields := []awsvault.OpField{
+		{Label: awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT, Type: awsvault.FIELD_TYPE_TEXT, Value: accessKeyId},
+		{Label: awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT, Type: awsvault.FIELD_TYPE_PASSWORD, Value: secretAccessKey},
+	}
+
+	otpRequired := false
+	survey.AskOne(&survey.Confirm{
+		Message: "Do you like to add a one-time password for the MFA device?",
+	}, &otpRequired)
+
+	if otpRequired {
+		var otp string
+		survey.AskOne(&survey.Password{
+			Message: "Enter the secret or the otpauth:// URI of the MFA device:",
+		}, &otp, survey.WithValidator(survey.MinLength(16)))
+
+		fields = append(fields, awsvault.OpField{Label: awsvault.OTP_FIELD_LABEL, Type: awsvault.FIELD_TYPE_OTP, Value: otp})
+	}
+
+	_, err = awsvault.CreateItem(commandClient, vaultName, title, fields)
+	handleError(err)
+	fmt.Printf("Created the item %s inside of the vault %s.\n", title, vaultName)
+
+	createProfile := true
+	survey.AskOne(&survey.Confirm{
+		Message: "Do you like to create a profile for the new item?",
+		Default: true,
+	}, &createProfile)
+
+	if createProfile {
+		runAwsConfigCommand(opaws.AWS_FILE_PATH, vaultName, title)
+	}
+}
+
+func addItemCmd() {
+	var vaultName string
+	var title string
+	var csvPath string
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_ITEM,
+		Short: "Functionality to administrate the AWS items inside of 1password",
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Creates an item with AWS credentials inside of 1password",
+		Long:  "Creates an item with the access key of an IAM user inside of 1password. The access key is imported from the CSV file of the IAM console or entered manually.\nAfterwards a profile for the item can be created.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runItemCreateCommand(vaultName, title, csvPath)
+		},
+	}
+	createCmd.Flags().StringVarP(&vaultName, "vault", "v", "", "The vault for the new item")
+	createCmd.Flags().StringVarP(&title, "title", "t", "", "The name of the new item")
+	createCmd.Flags().StringVar(&csvPath, "csv", "", "The access key CSV file downloaded from the IAM console")
+	createCmd.RegisterFlagCompletionFunc("vault", completeVaultFlag)
+
+	cmd.AddCommand(createCmd)
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const STATIC_PROFILE_SUFFIX = "-static"
+
+func runLoginCommand(profileName, targetProfileName string, forceCache, writeCredentials bool) {
+	profile := loadProfile(profileName)
+
+	credentials, err := getCredentials(profile, forceCache)
+	handleError(err)
+
+	if writeCredentials {
+		if targetProfileName == "" {
+			targetProfileName = profileName + STATIC_PROFILE_SUFFIX
+		}
+		// Static credentials take precedence over the credential_process, so the profile would fail once they expire.
+		for _, name := range getProfileNames() {
+			if name == targetProfileName {
+				handleError(fmt.Errorf("The profile %s uses %s inside of %s, choose another --target-profile", targetProfileName, config.COMMAND_ROOT, opaws.AWS_FILE_PATH))
+			}
+		}
+
+		c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_CREDENTIALS_FILE_PATH)
+		handleError(c.WriteCredentials(targetProfileName, credentials))
+		fmt.Printf("Wrote the credentials as profile %s to %s.\n", targetProfileName, c.GetPath())
+	}
+
+	fmt.Printf("Logged in with the profile %s until %s.\n", profileName, credentials.Expiration.Local().Format(time.RFC1123))
+}
+
+func runLogoutCommand(profileName string) {
+	c := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_CREDENTIALS_FILE_PATH)
+	handleError(c.RemoveCredentials(profileName))
+	fmt.Printf("Removed the credentials of the profile %s from %s.\n", profileName, c.GetPath())
+}
+
+func addLoginCmd() {
+	var targetProfileName string
+	var forceCache bool
+	var writeCredentials bool
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_LOGIN + " <profile>",
+		Short: "Logs in with a profile and optionally writes the credentials into the .aws/credentials file",
+		Long:  "Gets the credentials of an " + config.COMMAND_ROOT + " profile from the .aws/config file.\nWith --write-credentials the temporary credentials are written into the .aws/credentials file for tools, that only support static credentials.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runLoginCommand(args[0], targetProfileName, forceCache, writeCredentials)
+		},
+	}
+	cmd.Flags().BoolVarP(&writeCredentials, "write-credentials", "w", false, "To write the credentials into the .aws/credentials file")
+	cmd.Flags().StringVarP(&targetProfileName, "target-profile", "t", "", "The profile name inside of the .aws/credentials file. The name of the profile with the suffix "+STATIC_PROFILE_SUFFIX+" is used by default")
+	cmd.Flags().BoolVarP(&forceCache, "force", "f", false, "To force the execution without using the cache")
+	rootCMD.AddCommand(cmd)
+}
+
+func addLogoutCmd() {
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_LOGOUT + " <profile>",
+		Short: "Removes the credentials of a profile from the .aws/credentials file",
+		Long:  "Removes the credentials, that have been written by `" + config.COMMAND_ROOT + " " + config.COMMAND_LOGIN + " --write-credentials`, from the .aws/credentials file.\nThe profile is the name of the profile inside of the .aws/credentials file.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeCredentialsProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runLogoutCommand(args[0])
+		},
+	}
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+
+	"github.com/spf13/cobra"
+)
+
+func runMfaEnrollCommand(profile *opaws.OpProfile, deviceName string) {
+	// The one-time password of the item is used for the MFA, so a second one would be ambiguous.
+	entries, err := awsvault.GetEntries(&awsvault.CommandClientDefault{}, profile.Vault, profile.Item)
+	handleError(err)
+	for _, entry := range entries {
+		if entry.Type == "OTP" {
+			handleError(fmt.Errorf("The item %s already contains a one-time password", profile.Item))
+		}
+	}
+
+	serialNumber, err := opaws.New(getVaultClient(profile), &opaws.OpAwsDefaultInput{}).EnrollMFA(deviceName)
+	handleError(err)
+
+	fmt.Printf("Enabled the virtual MFA device %s and stored its one-time password inside of the item %s.\n", serialNumber, profile.Item)
+	fmt.Printf("Use it inside of profiles with `-m %s`.\n", serialNumber)
+}
+
+func addMfaCmd() {
+	profile := &opaws.OpProfile{}
+	var deviceName string
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_MFA,
+		Short: "Functionality to administrate the MFA devices of the IAM users inside of 1password",
+	}
+
+	enrollCmd := &cobra.Command{
+		Use:   "enroll <vault> <item>",
+		Short: "Creates and enables a virtual MFA device for the IAM user inside of 1password",
+		Long:  "Creates a virtual MFA device for the IAM user of the access key inside of 1password, stores its secret as one-time password inside of the item and enables the device.\nThe serial number of the device is printed for the use inside of profiles.",
+		Args:  cobra.ExactArgs(2),
+
+		ValidArgsFunction: completeVaultItem,
+		Run: func(cmd *cobra.Command, args []string) {
+			profile.Vault = args[0]
+			profile.Item = args[1]
+			resolveProfile(profile)
+			runMfaEnrollCommand(profile, deviceName)
+		},
+	}
+	enrollCmd.Flags().StringVar(&deviceName, "device-name", "", "The name of the virtual MFA device. The name of the IAM user is used, when it is not set")
+	enrollCmd.Flags().StringVarP(&profile.LabelAccessKey, "label-accesskey", "k", "", "To override the label field name in 1password for the AWS_ACCESS_KEY_ID (default \""+awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	enrollCmd.Flags().StringVarP(&profile.LabelSecretAccessKey, "label-secret-accesskey", "s", "", "To override the label field name in 1password for the AWS_SECRET_ACCESS_KEY (default \""+awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+
+	registerLabelCompletion(enrollCmd)
+
+	cmd.AddCommand(enrollCmd)
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+
+	"github.com/spf13/cobra"
+)
+
+func runRotateCommand(profile *opaws.OpProfile) {
+	fmt.Printf("Rotating the access key of the item %s inside of the vault %s...\n", profile.Item, profile.Vault)
+
+	accessKeyId, err := opaws.New(getVaultClient(profile), &opaws.OpAwsDefaultInput{}).RotateAccessKey()
+	handleError(err)
+
+	fmt.Printf("The access key has been rotated. The new access key %s is stored inside of 1password and the old access key has been deleted.\n", accessKeyId)
+}
+
+func addRotateCmd() {
+	profile := &opaws.OpProfile{}
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_ROTATE + " <vault> <item>",
+		Short: "Rotates the access key of the IAM user inside of 1password",
+		Long:  "Creates a new access key for the IAM user, writes it into the item inside of 1password, verifies it and deletes the old access key.\nWhen a step fails, the previous steps are rolled back.",
+		Args:  cobra.ExactArgs(2),
+
+		ValidArgsFunction: completeVaultItem,
+		Run: func(cmd *cobra.Command, args []string) {
+			profile.Vault = args[0]
+			profile.Item = args[1]
+			resolveProfile(profile)
+			runRotateCommand(profile)
+		},
+	}
+	cmd.Flags().StringVarP(&profile.LabelAccessKey, "label-accesskey", "k", "", "To override the label field name in 1password for the AWS_ACCESS_KEY_ID (default \""+awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	cmd.Flags().StringVarP(&profile.LabelSecretAccessKey, "label-secret-accesskey", "s", "", "To override the label field name in 1password for the AWS_SECRET_ACCESS_KEY (default \""+awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT+"\" or the settings)")
+	registerLabelCompletion(cmd)
+	rootCMD.AddCommand(cmd)
+}
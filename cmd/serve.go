@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/server"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/spf13/cobra"
+)
+
+func runServeCommand(profileName string, port int, imds bool) {
+	profile := loadProfile(profileName)
+
+	token, err := server.GenerateToken()
+	handleError(err)
+
+	credentialsServer := server.New(func(force bool) (*sts.Credentials, error) {
+		return getCredentials(profile, force)
+	}, token, profileName)
+	credentialsServer.UseImds(imds)
+
+	// Fetch the credentials before listening, so that 1password asks right away and not within the first request.
+	_, err = credentialsServer.GetCredentials()
+	handleError(err)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	handleError(err)
+
+	endpoint := "http://" + listener.Addr().String()
+	fmt.Printf("export AWS_CONTAINER_CREDENTIALS_FULL_URI=%s%s\n", endpoint, server.ECS_CREDENTIALS_PATH)
+	fmt.Printf("export AWS_CONTAINER_AUTHORIZATION_TOKEN=%s\n", token)
+	if imds {
+		fmt.Printf("export AWS_EC2_METADATA_SERVICE_ENDPOINT=%s\n", endpoint)
+	}
+
+	done := make(chan struct{})
+	go credentialsServer.RefreshLoop(server.DEFAULT_REFRESH_INTERVAL, done, func(err error) {
+		logger.Error("Unable to refresh the credentials", "error", err)
+	})
+
+	httpServer := &http.Server{Handler: credentialsServer.Handler()}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		close(done)
+		httpServer.Close()
+	}()
+
+	err = httpServer.Serve(listener)
+	if err != http.ErrServerClosed {
+		handleError(err)
+	}
+}
+
+func addServeCmd() {
+	var port int
+	var imds bool
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_SERVE + " <profile>",
+		Short: "Serves the credentials of a profile on a local endpoint",
+		Long:  "Serves the credentials of an " + config.COMMAND_ROOT + " profile via the ECS container credentials protocol on localhost, for tools without credential_process support.\nThe printed variables have to be exported inside of the environment of the tool, while the server keeps running.\nThe credentials are refreshed before they expire.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runServeCommand(args[0], port, imds)
+		},
+	}
+	cmd.Flags().IntVarP(&port, "port", "p", 0, "The port on localhost to listen on. A random free port is used by default")
+	cmd.Flags().BoolVar(&imds, "imds", false, "To additionally serve the credentials via an IMDSv2 compatible endpoint")
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+
+	"github.com/spf13/cobra"
+)
+
+// getSettings returns the settings of the profile from the settings file and the environment.
+func getSettings(profileName, preset string) config.Settings {
+	file, err := config.LoadSettingsFile(config.GetSettingsFilePath())
+	handleError(err)
+
+	settings, err := file.Resolve(profileName, preset)
+	handleError(err)
+
+	return settings
+}
+
+// resolveProfile fills the values of the profile, that are not set by flags, from the settings and the defaults.
+func resolveProfile(profile *opaws.OpProfile) {
+	settings := getSettings(profile.Name, profile.Preset).Merge(config.Settings{
+		LabelAccessKey:       profile.LabelAccessKey,
+		LabelSecretAccessKey: profile.LabelSecretAccessKey,
+	})
+
+	profile.LabelAccessKey = settings.LabelAccessKey
+	if profile.LabelAccessKey == "" {
+		profile.LabelAccessKey = awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT
+	}
+	profile.LabelSecretAccessKey = settings.LabelSecretAccessKey
+	if profile.LabelSecretAccessKey == "" {
+		profile.LabelSecretAccessKey = awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT
+	}
+
+	profile.SessionName = settings.SessionName
+	profile.Region = settings.Region
+	profile.Duration = settings.GetDuration()
+	profile.MFASessionDuration = settings.GetMFASessionDuration()
+	profile.CacheDir = settings.CacheDir
+
+	if profile.Source != nil {
+		resolveProfile(profile.Source)
+	}
+}
+
+// loadProfile reads the profile from the config file and resolves its settings.
+func loadProfile(profileName string) *opaws.OpProfile {
+	profile, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH).GetProfile(profileName)
+	handleError(err)
+
+	resolveProfile(profile)
+	return profile
+}
+
+// loadProfiles reads all op2aws profiles from the config file and resolves their settings.
+func loadProfiles() []*opaws.OpProfile {
+	profiles, err := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, opaws.AWS_FILE_PATH).GetProfiles()
+	handleError(err)
+
+	for _, profile := range profiles {
+		resolveProfile(profile)
+	}
+	return profiles
+}
+
+// getSettingsScope returns the scope of the --preset and --profile flags, the defaults without them.
+func getSettingsScope(preset, profileName string) (string, string) {
+	if preset != "" && profileName != "" {
+		handleError(fmt.Errorf("Use either --preset or --profile"))
+	}
+
+	if preset != "" {
+		return config.SCOPE_PRESETS, preset
+	}
+
+	if profileName != "" {
+		return config.SCOPE_PROFILES, profileName
+	}
+
+	return config.SCOPE_DEFAULTS, ""
+}
+
+func runSettingsGetCommand(key, preset, profileName string) {
+	settings := getSettings(profileName, preset)
+
+	value, err := settings.Get(key)
+	handleError(err)
+	fmt.Println(value)
+}
+
+func runSettingsSetCommand(key, value, preset, profileName string) {
+	path := config.GetSettingsFilePath()
+	file, err := config.LoadSettingsFile(path)
+	handleError(err)
+
+	scope, name := getSettingsScope(preset, profileName)
+	handleError(file.Set(scope, name, key, value))
+	handleError(file.Write(path))
+}
+
+func runSettingsListCommand() {
+	file, err := config.LoadSettingsFile(config.GetSettingsFilePath())
+	handleError(err)
+
+	for _, setting := range file.List() {
+		fmt.Printf("%s=%s\n", setting[0], setting[1])
+	}
+}
+
+func completeSettingKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filterCompletions(config.SETTING_KEYS, nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeSettingKeyOrPreset(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	keys := append([]string{}, config.SETTING_KEYS...)
+	return filterCompletions(append(keys, config.SETTING_PRESET), nil, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func addSettingsCmd() {
+	var preset string
+	var profileName string
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_SETTINGS,
+		Short: "Functionality to administrate the settings inside of " + config.GetSettingsFilePath(),
+		Long:  "The settings are the defaults for the flags of the commands. The settings file contains the defaults, named presets and the settings of profiles.\nThe precedence is flags > environment variables (e.g. " + config.GetSettingEnv(config.SETTING_REGION) + ") > profile > defaults of the settings file.",
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Prints the value of the setting, that is used for the profile or preset",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeSettingKey,
+		Run: func(cmd *cobra.Command, args []string) {
+			runSettingsGetCommand(args[0], preset, profileName)
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Changes the setting of the defaults, a preset or a profile. An empty value removes the setting",
+		Args:  cobra.ExactArgs(2),
+
+		ValidArgsFunction: completeSettingKeyOrPreset,
+		Run: func(cmd *cobra.Command, args []string) {
+			runSettingsSetCommand(args[0], args[1], preset, profileName)
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Prints all settings of the settings file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runSettingsListCommand()
+		},
+	}
+
+	for _, c := range []*cobra.Command{getCmd, setCmd} {
+		c.Flags().StringVar(&preset, "preset", "", "The preset of the setting")
+		c.Flags().StringVar(&profileName, "profile", "", "The profile of the setting")
+		c.RegisterFlagCompletionFunc("profile", completeProfile)
+	}
+
+	cmd.AddCommand(getCmd, setCmd, listCmd)
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/logger"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func getShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+
+	if runtime.GOOS == "windows" {
+		if shell := os.Getenv("COMSPEC"); shell != "" {
+			return shell
+		}
+		return "cmd.exe"
+	}
+
+	return "/bin/sh"
+}
+
+func getShellEnvironment(profileName string, environment []string) []string {
+	return overrideEnvironment(append(environment, config.ENV_PROFILE+"="+profileName))
+}
+
+// overrideEnvironment returns the environment of op2aws with the variables of the overrides replaced.
+func overrideEnvironment(overrides []string) []string {
+	shellEnvironment := []string{}
+
+	for _, v := range os.Environ() {
+		name, _, _ := strings.Cut(v, "=")
+		overridden := false
+		for _, o := range overrides {
+			if strings.HasPrefix(o, name+"=") {
+				overridden = true
+				break
+			}
+		}
+
+		if !overridden {
+			shellEnvironment = append(shellEnvironment, v)
+		}
+	}
+
+	return append(shellEnvironment, overrides...)
+}
+
+func runShellCommand(profileName string, forceCache, nested bool) {
+	if activeProfile := os.Getenv(config.ENV_PROFILE); activeProfile != "" {
+		if !nested {
+			handleError(fmt.Errorf("You are already inside of an %s shell for the profile %s. Leave it first or use --nested to start another one inside of it", config.COMMAND_ROOT, activeProfile))
+		}
+
+		logger.Warn("Starting a nested shell inside of the shell of another profile", "profile", activeProfile)
+	}
+
+	profile := loadProfile(profileName)
+
+	credentials, err := getCredentials(profile, forceCache)
+	handleError(err)
+
+	environment := getCredentialsEnvironment(credentials)
+	if credentials.Expiration != nil {
+		environment = append(environment, "AWS_CREDENTIAL_EXPIRATION="+credentials.Expiration.UTC().Format(time.RFC3339))
+
+		expirationTimer := time.AfterFunc(time.Until(*credentials.Expiration), func() {
+			fmt.Fprintf(
+				os.Stderr,
+				"\n%s: the credentials of the profile %s expired at %s. Leave this shell and run `%s %s %s` again to get new credentials.\n",
+				config.COMMAND_ROOT,
+				profileName,
+				credentials.Expiration.Local().Format(time.RFC1123),
+				config.COMMAND_ROOT,
+				config.COMMAND_SHELL,
+				profileName,
+			)
+		})
+		defer expirationTimer.Stop()
+	}
+
+	startShell(getShellEnvironment(profileName, environment))
+}
+
+// startShell runs $SHELL with the environment and exits with its exit code.
+func startShell(environment []string) {
+	shell := exec.Command(getShell())
+	shell.Stdin = os.Stdin
+	shell.Stdout = os.Stdout
+	shell.Stderr = os.Stderr
+	shell.Env = environment
+
+	// The shell receives the interrupts of the terminal by itself, op2aws only waits for it.
+	signal.Ignore(os.Interrupt)
+
+	err := shell.Run()
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		os.Exit(exitError.ExitCode())
+	}
+	handleError(err)
+}
+
+func addShellCmd() {
+	var forceCache bool
+	var nested bool
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_SHELL + " <profile>",
+		Short: "Starts a shell with the credentials of a profile",
+		Long:  "Starts $SHELL with the credentials of an " + config.COMMAND_ROOT + " profile from the .aws/config file inside of the environment.\nThe variable " + config.ENV_PROFILE + " contains the name of the profile and can be used to show it inside of the prompt.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runShellCommand(args[0], forceCache, nested)
+		},
+	}
+	cmd.Flags().BoolVarP(&forceCache, "force", "f", false, "To force the execution without using the cache")
+	cmd.Flags().BoolVar(&nested, "nested", false, "To allow starting the shell inside of another "+config.COMMAND_ROOT+" shell")
+	rootCMD.AddCommand(cmd)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/history"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/opaws"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+)
+
+const USE_PAGE_SIZE = 15
+
+// fuzzyMatch returns whether the characters of the filter appear in the value in the same order. Spaces are ignored.
+func fuzzyMatch(filter, value string) bool {
+	value = strings.ToLower(value)
+	for _, r := range strings.ToLower(filter) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		i := strings.IndexRune(value, r)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(string(r)):]
+	}
+
+	return true
+}
+
+// getCacheFreshness returns how long the cached credentials of the profile are valid.
+func getCacheFreshness(profile *opaws.OpProfile) string {
+	credentials := getCacheClient(profile).Peek()
+	if credentials == nil {
+		return "-"
+	}
+
+	return "valid for " + time.Until(*credentials.Expiration).Round(time.Minute).String()
+}
+
+// getUseOptions returns the aligned lines of the picker with the account, the role and the cache of every profile.
+func getUseOptions(profiles []*opaws.OpProfile) []string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 3, ' ', 0)
+	for _, profile := range profiles {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\n",
+			profile.Name,
+			formatOptionalValue(profile.GetAccountId()),
+			formatOptionalValue(profile.GetRoleName()),
+			getCacheFreshness(profile),
+		)
+	}
+	w.Flush()
+
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// sortProfilesByRecent returns the profiles with the recently used ones first.
+func sortProfilesByRecent(profiles []*opaws.OpProfile, recent []string) []*opaws.OpProfile {
+	byName := map[string]*opaws.OpProfile{}
+	names := []string{}
+	for _, profile := range profiles {
+		byName[profile.Name] = profile
+		names = append(names, profile.Name)
+	}
+
+	sorted := []*opaws.OpProfile{}
+	for _, name := range history.SortByRecent(names, recent) {
+		sorted = append(sorted, byName[name])
+	}
+
+	return sorted
+}
+
+// askUseProfile shows the picker on stderr, since stdout is evaluated by the shell.
+func askUseProfile() string {
+	checkTerminal()
+
+	profiles := loadProfiles()
+	if len(profiles) == 0 {
+		handleError(fmt.Errorf("There are no %s profiles in %s, add one with `%s %s`", config.COMMAND_ROOT, opaws.AWS_FILE_PATH, config.COMMAND_ROOT, config.COMMAND_CONFIG))
+	}
+
+	recent, err := history.ReadRecent(config.GetRecentFilePath())
+	if err != nil {
+		logger.Error("Unable to read the recently used profiles", "path", config.GetRecentFilePath(), "error", err)
+	}
+	sorted := sortProfilesByRecent(profiles, recent)
+
+	var index int
+	err = survey.AskOne(&survey.Select{
+		Message:  "Select the profile:",
+		Options:  getUseOptions(sorted),
+		PageSize: USE_PAGE_SIZE,
+		Filter:   func(filter, value string, _ int) bool { return fuzzyMatch(filter, value) },
+	}, &index, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr))
+	handleError(err)
+
+	return sorted[index].Name
+}
+
+func runUseCommand(profileName string, shell bool) {
+	if profileName == "" {
+		profileName = askUseProfile()
+	} else {
+		loadProfile(profileName)
+	}
+
+	if err := history.AddRecent(config.GetRecentFilePath(), profileName); err != nil {
+		logger.Error("Unable to record the recently used profile", "path", config.GetRecentFilePath(), "error", err)
+	}
+
+	// Credentials inside of the environment take precedence over AWS_PROFILE.
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		logger.Warn("AWS_ACCESS_KEY_ID is set and is used instead of the profile", "profile", profileName)
+	}
+
+	if shell {
+		startShell(overrideEnvironment([]string{"AWS_PROFILE=" + profileName}))
+		return
+	}
+
+	fmt.Println("export AWS_PROFILE=" + opaws.JoinCommandLine([]string{profileName}, ""))
+}
+
+func addUseCmd() {
+	var shell bool
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_USE + " [<profile>]",
+		Short: "Selects a profile for AWS_PROFILE",
+		Long:  "Shows a searchable list of the " + config.COMMAND_ROOT + " profiles with their account, role and cached credentials, the recently used profiles first.\nThe selected profile is printed as `export AWS_PROFILE=...` for `eval \"$(" + config.COMMAND_ROOT + " " + config.COMMAND_USE + ")\"` or set inside of a new $SHELL with --shell.\nType to filter the list, the typed characters have to appear in the same order, e.g. pa for prod-admin.",
+		Args:  cobra.MaximumNArgs(1),
+
+		ValidArgsFunction: completeProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			profileName := ""
+			if len(args) == 1 {
+				profileName = args[0]
+			}
+			runUseCommand(profileName, shell)
+		},
+	}
+	cmd.Flags().BoolVar(&shell, "shell", false, "To start $SHELL with AWS_PROFILE instead of printing the export")
+	rootCMD.AddCommand(cmd)
+}
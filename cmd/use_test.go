@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"nextunit/op2aws/opaws"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	testCases := []struct {
+		filter   string
+		value    string
+		expected bool
+	}{
+		{filter: "", value: "prod-admin", expected: true},
+		{filter: "pa", value: "prod-admin", expected: true},
+		{filter: "prod admin", value: "prod-admin", expected: true},
+		{filter: " p a ", value: "prod-admin", expected: true},
+		{filter: "PROD", value: "prod-admin", expected: true},
+		{filter: "prod", value: "PROD-Admin", expected: true},
+		{filter: "ap", value: "prod-admin", expected: false},
+		{filter: "admin prod", value: "prod-admin", expected: false},
+		{filter: "pp", value: "prod-admin", expected: false},
+		{filter: "prod-admin-2", value: "prod-admin", expected: false},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.filter+" "+v.value, func(t *testing.T) {
+			assert.Equal(t, v.expected, fuzzyMatch(v.filter, v.value))
+		})
+	}
+}
+
+func TestGetUseOptionsSortedByRecent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	profiles := []*opaws.OpProfile{
+		{Name: "dev", AssumeRole: "arn:aws:iam::111111111111:role/Developer"},
+		{Name: "prod-admin", AssumeRole: "arn:aws:iam::222222222222:role/path/Admin"},
+		{Name: "static"},
+	}
+
+	testCases := []struct {
+		name     string
+		recent   []string
+		expected []string
+	}{
+		{
+			name:   "without recent profiles",
+			recent: nil,
+			expected: []string{
+				"dev          111111111111   Developer   -",
+				"prod-admin   222222222222   Admin       -",
+				"static       -              -           -",
+			},
+		},
+		{
+			name:   "with recent profiles",
+			recent: []string{"static", "removed", "prod-admin"},
+			expected: []string{
+				"static       -              -           -",
+				"prod-admin   222222222222   Admin       -",
+				"dev          111111111111   Developer   -",
+			},
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			sorted := sortProfilesByRecent(profiles, v.recent)
+			options := getUseOptions(sorted)
+
+			assert.Equal(t, v.expected, options)
+			assert.Len(t, sorted, len(profiles), "Removed recent profiles should not be shown")
+			for i, option := range options {
+				assert.True(t, fuzzyMatch(sorted[i].Name, option), "The option %d should belong to the profile %s", i, sorted[i].Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/config"
+	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_JSON  = "json"
+
+	STAGE_CREDENTIALS = "credentials"
+)
+
+type verifyResult struct {
+	Profile    string
+	Success    bool
+	Account    string     `json:",omitempty"`
+	Arn        string     `json:",omitempty"`
+	Expiration *time.Time `json:",omitempty"`
+	Stage      string     `json:",omitempty"`
+	Error      string     `json:",omitempty"`
+	ExitCode   int        `json:",omitempty"`
+}
+
+func verifyVaultEntries(profile *opaws.OpProfile) error {
+	entries, err := awsvault.GetEntries(&awsvault.CommandClientDefault{}, profile.Vault, profile.Item)
+	if err != nil {
+		return &opaws.StageError{Stage: opaws.STAGE_VAULT, Err: fmt.Errorf("Unable to read the item %s inside of the vault %s: %w", profile.Item, profile.Vault, err)}
+	}
+
+	labels := map[string]bool{}
+	hasOTP := false
+	for _, entry := range entries {
+		labels[entry.Label] = true
+		hasOTP = hasOTP || entry.Type == "OTP"
+	}
+
+	for _, label := range []string{profile.LabelAccessKey, profile.LabelSecretAccessKey} {
+		if !labels[label] {
+			return &opaws.StageError{Stage: opaws.STAGE_VAULT, Kind: awsvault.ErrNotFound, Err: fmt.Errorf("The item %s has no field with the label %s", profile.Item, label)}
+		}
+	}
+
+	if profile.MFA != "" && !hasOTP {
+		return &opaws.StageError{Stage: opaws.STAGE_MFA, Err: fmt.Errorf("The item %s has no one-time password for the MFA", profile.Item)}
+	}
+
+	return nil
+}
+
+func getVerifyFailure(result verifyResult, err error) verifyResult {
+	result.Stage = opaws.GetStage(err)
+	if result.Stage == "" {
+		result.Stage = STAGE_CREDENTIALS
+	}
+	result.Error = redact.String(err.Error())
+	result.ExitCode = getExitCode(err)
+
+	return result
+}
+
+func verifyProfile(profile *opaws.OpProfile, forceCache bool, maxKeyAge int) verifyResult {
+	result := verifyResult{Profile: profile.Name}
+
+	if err := verifyVaultEntries(profile); err != nil {
+		return getVerifyFailure(result, err)
+	}
+
+	credentials, err := getCredentials(profile, forceCache)
+	if err != nil {
+		return getVerifyFailure(result, err)
+	}
+	result.Expiration = credentials.Expiration
+
+	identity, err := opaws.New(nil, &opaws.OpAwsDefaultInput{}).GetCallerIdentity(credentials)
+	if err != nil {
+		return getVerifyFailure(result, err)
+	}
+
+	if maxKeyAge > 0 {
+		age, err := opaws.New(getVaultClient(profile), &opaws.OpAwsDefaultInput{}).GetAccessKeyAge()
+		if err != nil {
+			return getVerifyFailure(result, err)
+		}
+
+		if days := int(age.Hours() / 24); days > maxKeyAge {
+			err := fmt.Errorf("The access key is %d days old, rotate it with `%s %s %s %s`", days, config.COMMAND_ROOT, config.COMMAND_ROTATE, profile.Vault, profile.Item)
+			return getVerifyFailure(result, &opaws.StageError{Stage: opaws.STAGE_KEY_AGE, Err: err})
+		}
+	}
+
+	result.Success = true
+	result.Account = *identity.Account
+	result.Arn = *identity.Arn
+	return result
+}
+
+func printVerifyResults(results []verifyResult, output string) {
+	if output == OUTPUT_JSON {
+		content, err := json.MarshalIndent(results, "", "  ")
+		handleError(err)
+		fmt.Println(string(content))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tSTATUS\tACCOUNT\tARN\tEXPIRATION")
+	for _, r := range results {
+		if !r.Success {
+			fmt.Fprintf(w, "%s\tFAILED (%s)\t\t%s\t\n", r.Profile, r.Stage, r.Error)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\tOK\t%s\t%s\t%s\n", r.Profile, r.Account, r.Arn, r.Expiration.Local().Format(time.RFC1123))
+	}
+	w.Flush()
+}
+
+func runVerifyCommand(profileName, output string, forceCache bool, maxKeyAge int) {
+	if output != OUTPUT_TABLE && output != OUTPUT_JSON {
+		handleError(fmt.Errorf("The output format %s is not supported, use %s or %s", output, OUTPUT_TABLE, OUTPUT_JSON))
+	}
+
+	var profiles []*opaws.OpProfile
+	if profileName == "all" {
+		profiles = loadProfiles()
+	} else {
+		profiles = []*opaws.OpProfile{loadProfile(profileName)}
+	}
+
+	// The command exits with the code of the first failed profile.
+	results := []verifyResult{}
+	exitCode := 0
+	for _, profile := range profiles {
+		result := verifyProfile(profile, forceCache, maxKeyAge)
+		if exitCode == 0 && !result.Success {
+			exitCode = result.ExitCode
+		}
+		results = append(results, result)
+	}
+
+	printVerifyResults(results, output)
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+func addVerifyCmd() {
+	var output string
+	var forceCache bool
+	var maxKeyAge int
+
+	cmd := &cobra.Command{
+		Use:   config.COMMAND_VERIFY + " <profile|all>",
+		Short: "Verifies, that profiles are working",
+		Long:  "Verifies " + config.COMMAND_ROOT + " profiles from the .aws/config file: the fields inside of 1password are checked, the credentials are generated and the identity is requested from AWS.\nFailures are reported per profile with the failing stage.",
+		Args:  cobra.ExactArgs(1),
+
+		ValidArgsFunction: completeVerifyProfile,
+		Run: func(cmd *cobra.Command, args []string) {
+			runVerifyCommand(args[0], output, forceCache, maxKeyAge)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", OUTPUT_TABLE, "The output format: table or json")
+	cmd.Flags().BoolVarP(&forceCache, "force", "f", false, "To force the execution without using the cache")
+	cmd.Flags().IntVar(&maxKeyAge, "max-key-age", 0, "The maximum age in days of the access key inside of 1password. 0 disables the check")
+	rootCMD.AddCommand(cmd)
+}
@@ -1,7 +1,62 @@
 package config
 
+import (
+	"os"
+	"path/filepath"
+)
+
 var (
-	COMMAND_ROOT   = "op2aws"
-	COMMAND_CLI    = "cli"
-	COMMAND_CONFIG = "config"
+	COMMAND_ROOT    = "op2aws"
+	COMMAND_CLI     = "cli"
+	COMMAND_CONFIG  = "config"
+	COMMAND_SHELL   = "shell"
+	COMMAND_SERVE   = "serve"
+	COMMAND_CONSOLE = "console"
+	COMMAND_LOGIN   = "login"
+	COMMAND_LOGOUT  = "logout"
+	COMMAND_AGENT   = "agent"
+	COMMAND_VERIFY  = "verify"
+	COMMAND_ROTATE  = "rotate"
+	COMMAND_AUDIT   = "audit"
+	COMMAND_ITEM    = "item"
+	COMMAND_MFA     = "mfa"
+	COMMAND_HISTORY = "history"
+
+	COMMAND_COMPLETION = "completion"
+	COMMAND_SETTINGS   = "settings"
+	COMMAND_USE        = "use"
+
+	ENV_PROFILE   = "OP2AWS_PROFILE"
+	ENV_LOG_LEVEL = "OP2AWS_LOG_LEVEL"
+
+	AGENT_SOCKET_NAME = "agent.sock"
+	LOG_FILE_NAME     = "op2aws.log"
+	HISTORY_FILE_NAME = "history.jsonl"
+	RECENT_FILE_NAME  = "recent.json"
 )
+
+// GetStateDir returns the directory for the state of op2aws, following the XDG base directory specification.
+func GetStateDir() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		stateHome = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+
+	return filepath.Join(stateHome, COMMAND_ROOT)
+}
+
+func GetAgentSocketPath() string {
+	return filepath.Join(GetStateDir(), AGENT_SOCKET_NAME)
+}
+
+func GetLogFilePath() string {
+	return filepath.Join(GetStateDir(), LOG_FILE_NAME)
+}
+
+func GetHistoryFilePath() string {
+	return filepath.Join(GetStateDir(), HISTORY_FILE_NAME)
+}
+
+func GetRecentFilePath() string {
+	return filepath.Join(GetStateDir(), RECENT_FILE_NAME)
+}
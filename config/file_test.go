@@ -0,0 +1,34 @@
+package config_test
+
+import (
+	"nextunit/op2aws/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceFile(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "op2aws")
+	path := filepath.Join(dir, "file")
+
+	assert.Nil(config.ReplaceFile(path, []byte("old"), 0644), "The directory should be created")
+	assert.Nil(config.ReplaceFile(path, []byte("new"), 0600))
+
+	content, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("new", string(content))
+
+	info, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(0600, int(info.Mode().Perm()))
+
+	assert.Nil(os.Mkdir(filepath.Join(dir, "directory"), 0700))
+	assert.NotNil(config.ReplaceFile(filepath.Join(dir, "directory"), []byte("new"), 0600), "A directory can't be replaced")
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(entries, 2, "No temporary file should be left")
+}
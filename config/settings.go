@@ -0,0 +1,435 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	SETTINGS_FILE_NAME = "config.yaml"
+
+	SETTING_LABEL_ACCESSKEY        = "label_accesskey"
+	SETTING_LABEL_SECRET_ACCESSKEY = "label_secret_accesskey"
+	SETTING_SESSION_NAME           = "session_name"
+	SETTING_CACHE_DIR              = "cache_dir"
+	SETTING_REGION                 = "region"
+	SETTING_DURATION               = "duration"
+	SETTING_MFA_SESSION_DURATION   = "mfa_session_duration"
+	SETTING_PRESET                 = "preset"
+
+	SETTING_KEYS = []string{
+		SETTING_LABEL_ACCESSKEY,
+		SETTING_LABEL_SECRET_ACCESSKEY,
+		SETTING_SESSION_NAME,
+		SETTING_CACHE_DIR,
+		SETTING_REGION,
+		SETTING_DURATION,
+		SETTING_MFA_SESSION_DURATION,
+	}
+
+	// The environment variables of the settings, e.g. OP2AWS_REGION.
+	ENV_SETTINGS_PREFIX = "OP2AWS_"
+	ENV_PRESET          = "OP2AWS_PRESET"
+
+	SCOPE_DEFAULTS = "defaults"
+	SCOPE_PRESETS  = "presets"
+	SCOPE_PROFILES = "profiles"
+
+	MIN_DURATION = 15 * time.Minute
+	MAX_DURATION = 36 * time.Hour
+
+	SESSION_NAME_PATTERN = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+	REGION_PATTERN       = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+)
+
+// Settings are the defaults for the flags of the commands. Empty values are not set.
+type Settings struct {
+	LabelAccessKey       string `yaml:"label_accesskey,omitempty"`
+	LabelSecretAccessKey string `yaml:"label_secret_accesskey,omitempty"`
+	SessionName          string `yaml:"session_name,omitempty"`
+	CacheDir             string `yaml:"cache_dir,omitempty"`
+	Region               string `yaml:"region,omitempty"`
+	Duration             string `yaml:"duration,omitempty"`
+	MFASessionDuration   string `yaml:"mfa_session_duration,omitempty"`
+}
+
+// ProfileSettings are the settings of one profile, which can be based on a preset.
+type ProfileSettings struct {
+	Preset   string `yaml:"preset,omitempty"`
+	Settings `yaml:",inline"`
+}
+
+// SettingsFile is the config.yaml with the global defaults, the named presets and the settings of the profiles.
+type SettingsFile struct {
+	Defaults Settings                   `yaml:"defaults,omitempty"`
+	Presets  map[string]Settings        `yaml:"presets,omitempty"`
+	Profiles map[string]ProfileSettings `yaml:"profiles,omitempty"`
+}
+
+// GetConfigDir returns the directory for the configuration of op2aws, following the XDG base directory specification.
+func GetConfigDir() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+
+	return filepath.Join(configHome, COMMAND_ROOT)
+}
+
+func GetSettingsFilePath() string {
+	return filepath.Join(GetConfigDir(), SETTINGS_FILE_NAME)
+}
+
+func (s *Settings) field(key string) (*string, error) {
+	switch key {
+	case SETTING_LABEL_ACCESSKEY:
+		return &s.LabelAccessKey, nil
+	case SETTING_LABEL_SECRET_ACCESSKEY:
+		return &s.LabelSecretAccessKey, nil
+	case SETTING_SESSION_NAME:
+		return &s.SessionName, nil
+	case SETTING_CACHE_DIR:
+		return &s.CacheDir, nil
+	case SETTING_REGION:
+		return &s.Region, nil
+	case SETTING_DURATION:
+		return &s.Duration, nil
+	case SETTING_MFA_SESSION_DURATION:
+		return &s.MFASessionDuration, nil
+	}
+
+	return nil, fmt.Errorf("The setting %s does not exist, use one of %s", key, strings.Join(SETTING_KEYS, ", "))
+}
+
+// Get returns the value of the setting.
+func (s Settings) Get(key string) (string, error) {
+	value, err := s.field(key)
+	if err != nil {
+		return "", err
+	}
+
+	return *value, nil
+}
+
+// Set validates and changes the setting. An empty value removes it.
+func (s *Settings) Set(key, value string) error {
+	field, err := s.field(key)
+	if err != nil {
+		return err
+	}
+
+	if value != "" {
+		if err := ValidateSetting(key, value); err != nil {
+			return err
+		}
+	}
+
+	*field = value
+	return nil
+}
+
+func (s Settings) isEmpty() bool {
+	return s == Settings{}
+}
+
+// Merge returns the settings with the values of other on top.
+func (s Settings) Merge(other Settings) Settings {
+	for _, key := range SETTING_KEYS {
+		value, _ := other.Get(key)
+		if value != "" {
+			field, _ := s.field(key)
+			*field = value
+		}
+	}
+
+	return s
+}
+
+func (s Settings) validate() error {
+	for _, key := range SETTING_KEYS {
+		value, _ := s.Get(key)
+		if value == "" {
+			continue
+		}
+
+		if err := ValidateSetting(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDuration returns the parsed duration or 0, when it is not set.
+func (s Settings) GetDuration() time.Duration {
+	duration, _ := time.ParseDuration(s.Duration)
+	return duration
+}
+
+func (s Settings) GetMFASessionDuration() time.Duration {
+	duration, _ := time.ParseDuration(s.MFASessionDuration)
+	return duration
+}
+
+// ValidateSetting checks a value of the setting.
+func ValidateSetting(key, value string) error {
+	switch key {
+	case SETTING_SESSION_NAME:
+		if !SESSION_NAME_PATTERN.MatchString(value) {
+			return fmt.Errorf("The session name %s has to be 2 to 64 characters of letters, digits and +=,.@_-", value)
+		}
+	case SETTING_REGION:
+		if !REGION_PATTERN.MatchString(value) {
+			return fmt.Errorf("The region %s is not a region like eu-central-1", value)
+		}
+	case SETTING_DURATION, SETTING_MFA_SESSION_DURATION:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("The duration %s is not a duration like 1h or 90m", value)
+		}
+
+		if duration < MIN_DURATION || duration > MAX_DURATION {
+			return fmt.Errorf("The duration %s has to be between %s and %s", value, MIN_DURATION, MAX_DURATION)
+		}
+	case SETTING_CACHE_DIR:
+		if !filepath.IsAbs(value) {
+			return fmt.Errorf("The cache dir %s has to be an absolute path", value)
+		}
+	}
+
+	return nil
+}
+
+// GetEnvSettings returns the settings of the environment variables.
+func GetEnvSettings() Settings {
+	settings := Settings{}
+	for _, key := range SETTING_KEYS {
+		field, _ := settings.field(key)
+		*field = os.Getenv(GetSettingEnv(key))
+	}
+
+	return settings
+}
+
+// GetSettingEnv returns the environment variable of the setting, e.g. OP2AWS_REGION for region.
+func GetSettingEnv(key string) string {
+	return ENV_SETTINGS_PREFIX + strings.ToUpper(key)
+}
+
+// Validate checks all settings and that the presets of the profiles exist.
+func (f *SettingsFile) Validate() error {
+	if err := f.Defaults.validate(); err != nil {
+		return fmt.Errorf("Invalid %s: %w", SCOPE_DEFAULTS, err)
+	}
+
+	for name, preset := range f.Presets {
+		if err := preset.validate(); err != nil {
+			return fmt.Errorf("Invalid preset %s: %w", name, err)
+		}
+	}
+
+	for name, profile := range f.Profiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("Invalid settings of the profile %s: %w", name, err)
+		}
+
+		if _, ok := f.Presets[profile.Preset]; profile.Preset != "" && !ok {
+			return fmt.Errorf("The preset %s of the profile %s does not exist", profile.Preset, name)
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns the settings of the profile: the defaults, the preset, the profile and the environment variables.
+// The given preset replaces the one of the profile.
+func (f *SettingsFile) Resolve(profileName, preset string) (Settings, error) {
+	profile := f.Profiles[profileName]
+
+	if preset == "" {
+		preset = os.Getenv(ENV_PRESET)
+	}
+	if preset == "" {
+		preset = profile.Preset
+	}
+
+	presetSettings, ok := f.Presets[preset]
+	if preset != "" && !ok {
+		return Settings{}, fmt.Errorf("The preset %s does not exist in %s", preset, GetSettingsFilePath())
+	}
+
+	envSettings := GetEnvSettings()
+	if err := envSettings.validate(); err != nil {
+		return Settings{}, fmt.Errorf("Invalid environment variable: %w", err)
+	}
+
+	return f.Defaults.Merge(presetSettings).Merge(profile.Settings).Merge(envSettings), nil
+}
+
+// getScope returns the settings of the defaults or of the preset or profile with the name.
+func (f *SettingsFile) getScope(scope, name string) (*Settings, error) {
+	switch scope {
+	case SCOPE_DEFAULTS:
+		return &f.Defaults, nil
+	case SCOPE_PRESETS:
+		settings := f.Presets[name]
+		return &settings, nil
+	case SCOPE_PROFILES:
+		settings := f.Profiles[name].Settings
+		return &settings, nil
+	}
+
+	return nil, fmt.Errorf("Unknown scope %s", scope)
+}
+
+// Set changes the setting of the scope. The preset of a profile is changed with the key preset.
+func (f *SettingsFile) Set(scope, name, key, value string) error {
+	if key == SETTING_PRESET {
+		if scope != SCOPE_PROFILES {
+			return fmt.Errorf("Only profiles can have a %s", SETTING_PRESET)
+		}
+
+		if _, ok := f.Presets[value]; value != "" && !ok {
+			return fmt.Errorf("The preset %s does not exist", value)
+		}
+
+		profile := f.Profiles[name]
+		profile.Preset = value
+		f.setProfile(name, profile)
+		return nil
+	}
+
+	settings, err := f.getScope(scope, name)
+	if err != nil {
+		return err
+	}
+
+	if err := settings.Set(key, value); err != nil {
+		return err
+	}
+
+	switch scope {
+	case SCOPE_PRESETS:
+		if f.Presets == nil {
+			f.Presets = map[string]Settings{}
+		}
+		f.Presets[name] = *settings
+		if settings.isEmpty() {
+			delete(f.Presets, name)
+		}
+	case SCOPE_PROFILES:
+		profile := f.Profiles[name]
+		profile.Settings = *settings
+		f.setProfile(name, profile)
+	}
+
+	return nil
+}
+
+func (f *SettingsFile) setProfile(name string, profile ProfileSettings) {
+	if f.Profiles == nil {
+		f.Profiles = map[string]ProfileSettings{}
+	}
+
+	f.Profiles[name] = profile
+	if profile.Preset == "" && profile.isEmpty() {
+		delete(f.Profiles, name)
+	}
+}
+
+// List returns every setting of the file as key and value, e.g. presets.work.region.
+func (f *SettingsFile) List() [][2]string {
+	list := [][2]string{}
+	add := func(prefix string, settings Settings) {
+		for _, key := range SETTING_KEYS {
+			if value, _ := settings.Get(key); value != "" {
+				list = append(list, [2]string{prefix + key, value})
+			}
+		}
+	}
+
+	add(SCOPE_DEFAULTS+".", f.Defaults)
+	for _, name := range GetSortedKeys(f.Presets) {
+		add(SCOPE_PRESETS+"."+name+".", f.Presets[name])
+	}
+	for _, name := range GetSortedKeys(f.Profiles) {
+		profile := f.Profiles[name]
+		if profile.Preset != "" {
+			list = append(list, [2]string{SCOPE_PROFILES + "." + name + "." + SETTING_PRESET, profile.Preset})
+		}
+		add(SCOPE_PROFILES+"."+name+".", profile.Settings)
+	}
+
+	return list
+}
+
+// GetPresetNames returns the names of the presets in alphabetical order.
+func (f SettingsFile) GetPresetNames() []string {
+	return GetSortedKeys(f.Presets)
+}
+
+// GetSortedKeys returns the keys of the map in alphabetical order.
+func GetSortedKeys[T any](m map[string]T) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// ParseSettingsFile decodes and validates the content of the config.yaml. Unknown keys are an error.
+func ParseSettingsFile(content []byte) (*SettingsFile, error) {
+	file := &SettingsFile{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// LoadSettingsFile reads the config.yaml. Without the file, there are no settings.
+func LoadSettingsFile(path string) (*SettingsFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &SettingsFile{}, nil
+		}
+		return nil, err
+	}
+
+	file, err := ParseSettingsFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+func (f *SettingsFile) Write(path string) error {
+	content, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return ReplaceFile(path, content, 0600)
+}
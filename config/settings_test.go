@@ -0,0 +1,167 @@
+package config_test
+
+import (
+	"nextunit/op2aws/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var SETTINGS_FILE_CONTENT = []byte(`
+defaults:
+  region: eu-central-1
+  duration: 1h
+presets:
+  work:
+    label_accesskey: work-key
+    region: us-east-1
+  sandbox:
+    session_name: sandbox
+profiles:
+  prod:
+    preset: work
+    duration: 2h
+  dev:
+    cache_dir: /tmp/op2aws
+`)
+
+func clearSettingsEnv(t *testing.T) {
+	t.Setenv(config.ENV_PRESET, "")
+	for _, key := range config.SETTING_KEYS {
+		t.Setenv(config.GetSettingEnv(key), "")
+	}
+}
+
+func TestParseSettingsFile(t *testing.T) {
+	file, err := config.ParseSettingsFile(SETTINGS_FILE_CONTENT)
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-central-1", file.Defaults.Region)
+	assert.Equal(t, "work-key", file.Presets["work"].LabelAccessKey)
+	assert.Equal(t, "work", file.Profiles["prod"].Preset)
+	assert.Equal(t, "2h", file.Profiles["prod"].Duration)
+	assert.Equal(t, []string{"sandbox", "work"}, file.GetPresetNames())
+
+	file, err = config.ParseSettingsFile([]byte{})
+	assert.Nil(t, err)
+	assert.Equal(t, &config.SettingsFile{}, file)
+}
+
+func TestParseSettingsFileErrors(t *testing.T) {
+	for _, content := range []string{
+		"defaults:\n  regoin: eu-central-1\n",
+		"defaults:\n  region: europe\n",
+		"defaults:\n  duration: 5m\n",
+		"defaults:\n  duration: 48h\n",
+		"presets:\n  work:\n    session_name: a b\n",
+		"profiles:\n  prod:\n    cache_dir: relative\n",
+		"profiles:\n  prod:\n    preset: missing\n",
+	} {
+		_, err := config.ParseSettingsFile([]byte(content))
+		assert.NotNil(t, err, content)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	clearSettingsEnv(t)
+
+	file, err := config.ParseSettingsFile(SETTINGS_FILE_CONTENT)
+	assert.Nil(t, err)
+
+	settings, err := file.Resolve("prod", "")
+	assert.Nil(t, err)
+	assert.Equal(t, config.Settings{LabelAccessKey: "work-key", Region: "us-east-1", Duration: "2h"}, settings)
+	assert.Equal(t, 2*time.Hour, settings.GetDuration())
+
+	settings, err = file.Resolve("dev", "")
+	assert.Nil(t, err)
+	assert.Equal(t, config.Settings{CacheDir: "/tmp/op2aws", Region: "eu-central-1", Duration: "1h"}, settings)
+
+	settings, err = file.Resolve("unknown", "sandbox")
+	assert.Nil(t, err)
+	assert.Equal(t, config.Settings{SessionName: "sandbox", Region: "eu-central-1", Duration: "1h"}, settings)
+
+	// The given preset replaces the preset of the profile.
+	settings, err = file.Resolve("prod", "sandbox")
+	assert.Nil(t, err)
+	assert.Equal(t, config.Settings{SessionName: "sandbox", Region: "eu-central-1", Duration: "2h"}, settings)
+
+	_, err = file.Resolve("prod", "missing")
+	assert.NotNil(t, err)
+}
+
+func TestResolveWithEnv(t *testing.T) {
+	clearSettingsEnv(t)
+
+	file, err := config.ParseSettingsFile(SETTINGS_FILE_CONTENT)
+	assert.Nil(t, err)
+
+	t.Setenv(config.GetSettingEnv(config.SETTING_REGION), "ap-south-1")
+	t.Setenv(config.GetSettingEnv(config.SETTING_DURATION), "30m")
+	t.Setenv(config.ENV_PRESET, "sandbox")
+
+	settings, err := file.Resolve("prod", "")
+	assert.Nil(t, err)
+	assert.Equal(t, config.Settings{SessionName: "sandbox", Region: "ap-south-1", Duration: "30m"}, settings)
+
+	t.Setenv(config.GetSettingEnv(config.SETTING_DURATION), "1d")
+	_, err = file.Resolve("prod", "")
+	assert.NotNil(t, err)
+}
+
+func TestSet(t *testing.T) {
+	file := &config.SettingsFile{}
+
+	assert.Nil(t, file.Set(config.SCOPE_DEFAULTS, "", config.SETTING_REGION, "eu-west-1"))
+	assert.Nil(t, file.Set(config.SCOPE_PRESETS, "work", config.SETTING_SESSION_NAME, "work"))
+	assert.Nil(t, file.Set(config.SCOPE_PROFILES, "prod", config.SETTING_PRESET, "work"))
+	assert.Nil(t, file.Set(config.SCOPE_PROFILES, "prod", config.SETTING_DURATION, "3h"))
+
+	assert.NotNil(t, file.Set(config.SCOPE_DEFAULTS, "", "unknown", "value"))
+	assert.NotNil(t, file.Set(config.SCOPE_DEFAULTS, "", config.SETTING_DURATION, "1s"))
+	assert.NotNil(t, file.Set(config.SCOPE_DEFAULTS, "", config.SETTING_MFA_SESSION_DURATION, "1s"))
+	assert.NotNil(t, file.Set(config.SCOPE_DEFAULTS, "", config.SETTING_PRESET, "work"))
+	assert.NotNil(t, file.Set(config.SCOPE_PROFILES, "prod", config.SETTING_PRESET, "missing"))
+
+	assert.Equal(t, [][2]string{
+		{"defaults.region", "eu-west-1"},
+		{"presets.work.session_name", "work"},
+		{"profiles.prod.preset", "work"},
+		{"profiles.prod.duration", "3h"},
+	}, file.List())
+
+	// Empty values remove the settings and empty presets and profiles.
+	assert.Nil(t, file.Set(config.SCOPE_PRESETS, "work", config.SETTING_SESSION_NAME, ""))
+	assert.Nil(t, file.Set(config.SCOPE_PROFILES, "prod", config.SETTING_PRESET, ""))
+	assert.Nil(t, file.Set(config.SCOPE_PROFILES, "prod", config.SETTING_DURATION, ""))
+
+	assert.Equal(t, [][2]string{{"defaults.region", "eu-west-1"}}, file.List())
+	assert.Empty(t, file.Presets)
+	assert.Empty(t, file.Profiles)
+}
+
+func TestWriteAndLoadSettingsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "op2aws", config.SETTINGS_FILE_NAME)
+
+	file, err := config.LoadSettingsFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &config.SettingsFile{}, file)
+
+	file, err = config.ParseSettingsFile(SETTINGS_FILE_CONTENT)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Write(path))
+
+	loaded, err := config.LoadSettingsFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, file, loaded)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 0600, int(info.Mode().Perm()))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1, "No temporary file should be left")
+}
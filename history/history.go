@@ -0,0 +1,178 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"nextunit/op2aws/logger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SOURCE_CACHE = "cache"
+	SOURCE_STS   = "sts"
+	SOURCE_AGENT = "agent"
+
+	ACCESS_KEY_ID_PREFIX_LENGTH = 8
+	MAX_RECORD_SIZE             = 64 * 1024
+)
+
+// Record is one issuance of credentials. Only the prefix of the access key id is recorded.
+type Record struct {
+	Time              time.Time
+	Profile           string `json:",omitempty"`
+	Vault             string `json:",omitempty"`
+	Item              string `json:",omitempty"`
+	RoleArn           string `json:",omitempty"`
+	MFA               string `json:",omitempty"`
+	Source            string
+	Expiration        *time.Time `json:",omitempty"`
+	AccessKeyIdPrefix string     `json:",omitempty"`
+	ParentPid         int
+	ParentProcess     string `json:",omitempty"`
+}
+
+// Filter selects the records of a profile inside of a time range. Empty values select everything.
+type Filter struct {
+	Profile string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f Filter) matches(record Record) bool {
+	if f.Profile != "" && record.Profile != f.Profile {
+		return false
+	}
+
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && record.Time.After(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// GetAccessKeyIdPrefix returns the beginning of the access key id, which is recorded instead of the full id.
+func GetAccessKeyIdPrefix(accessKeyId string) string {
+	if len(accessKeyId) <= ACCESS_KEY_ID_PREFIX_LENGTH {
+		return accessKeyId
+	}
+
+	return accessKeyId[:ACCESS_KEY_ID_PREFIX_LENGTH]
+}
+
+// GetParent returns the pid and the name of the process, that started op2aws, e.g. aws or terraform.
+func GetParent() (int, string) {
+	pid := os.Getppid()
+	return pid, getProcessName(pid)
+}
+
+// Append adds the record with one write to the end of the log.
+func Append(path string, record Record) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(content, '\n'))
+	return err
+}
+
+// Read returns the records of the log, that match the filter. Lines, that can't be decoded, are skipped.
+func Read(r io.Reader, filter Filter) ([]Record, error) {
+	records := []Record{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, MAX_RECORD_SIZE), MAX_RECORD_SIZE)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logger.Debug("Skipping an invalid history record", "line", line, "error", err)
+			continue
+		}
+
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// ReadFile returns the records of the log file. A missing file has no records.
+func ReadFile(path string, filter Filter) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Record{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file, filter)
+}
+
+// ParseTime parses the start of the time range: a RFC 3339 timestamp, a local date or a duration before now.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	t, _, err := parseTime(value, now)
+	return t, err
+}
+
+// ParseUntil parses the end of the time range like ParseTime. A date means the end of that day.
+func ParseUntil(value string, now time.Time) (time.Time, error) {
+	t, isDate, err := parseTime(value, now)
+	if isDate {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), err
+	}
+
+	return t, err
+}
+
+func parseTime(value string, now time.Time) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if d, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -d), false, nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), false, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return t, true, nil
+	}
+
+	return time.Time{}, false, fmt.Errorf("The time %s is neither a RFC 3339 timestamp, a date like 2006-01-02 nor a duration like 12h or 7d", value)
+}
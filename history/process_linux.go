@@ -0,0 +1,16 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func getProcessName(pid int) string {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
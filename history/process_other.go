@@ -0,0 +1,19 @@
+//go:build !linux && !windows
+
+package history
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func getProcessName(pid int) string {
+	output, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Base(strings.TrimSpace(string(output)))
+}
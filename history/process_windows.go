@@ -0,0 +1,28 @@
+package history
+
+import (
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// getProcessName looks the process up inside of a snapshot of all processes, because windows has no /proc.
+func getProcessName(pid int) string {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		if int(entry.ProcessID) == pid {
+			name := filepath.Base(windows.UTF16ToString(entry.ExeFile[:]))
+			return strings.TrimSuffix(name, filepath.Ext(name))
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"encoding/json"
+	"nextunit/op2aws/config"
+	"os"
+)
+
+const MAX_RECENT_PROFILES = 20
+
+// ReadRecent returns the recently used profiles, the last used one first.
+func ReadRecent(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	if err := json.Unmarshal(content, &names); err != nil {
+		return []string{}, nil
+	}
+
+	return names, nil
+}
+
+// AddRecent moves the profile to the front of the recently used profiles. Only the last MAX_RECENT_PROFILES are kept.
+func AddRecent(path, name string) error {
+	names, err := ReadRecent(path)
+	if err != nil {
+		return err
+	}
+
+	recent := []string{name}
+	for _, n := range names {
+		if n != name && len(recent) < MAX_RECENT_PROFILES {
+			recent = append(recent, n)
+		}
+	}
+
+	content, err := json.Marshal(recent)
+	if err != nil {
+		return err
+	}
+
+	return config.ReplaceFile(path, content, 0600)
+}
+
+// SortByRecent returns the names with the recently used ones first. The other names keep their order.
+func SortByRecent(names, recent []string) []string {
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+
+	sorted := []string{}
+	used := map[string]bool{}
+	for _, name := range recent {
+		if known[name] && !used[name] {
+			sorted = append(sorted, name)
+			used[name] = true
+		}
+	}
+
+	for _, name := range names {
+		if !used[name] {
+			sorted = append(sorted, name)
+		}
+	}
+
+	return sorted
+}
@@ -0,0 +1,62 @@
+package history_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/history"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "op2aws", "recent.json")
+
+	names, err := history.ReadRecent(path)
+	assert.Nil(t, err)
+	assert.Empty(t, names)
+
+	assert.Nil(t, history.AddRecent(path, "dev"))
+	assert.Nil(t, history.AddRecent(path, "prod"))
+	assert.Nil(t, history.AddRecent(path, "dev"))
+
+	names, err = history.ReadRecent(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, names)
+}
+
+func TestAddRecentKeepsTheLastProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recent.json")
+
+	for i := 0; i < history.MAX_RECENT_PROFILES+5; i++ {
+		assert.Nil(t, history.AddRecent(path, fmt.Sprintf("profile-%d", i)))
+	}
+
+	names, err := history.ReadRecent(path)
+	assert.Nil(t, err)
+	assert.Len(t, names, history.MAX_RECENT_PROFILES)
+	assert.Equal(t, fmt.Sprintf("profile-%d", history.MAX_RECENT_PROFILES+4), names[0])
+}
+
+func TestReadRecentWithInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recent.json")
+	assert.Nil(t, os.WriteFile(path, []byte("{invalid"), 0600))
+
+	names, err := history.ReadRecent(path)
+	assert.Nil(t, err)
+	assert.Empty(t, names)
+
+	assert.Nil(t, history.AddRecent(path, "dev"))
+	names, err = history.ReadRecent(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev"}, names)
+}
+
+func TestSortByRecent(t *testing.T) {
+	assert.Equal(t,
+		[]string{"prod", "dev", "admin", "sandbox"},
+		history.SortByRecent([]string{"admin", "dev", "prod", "sandbox"}, []string{"prod", "removed", "dev", "prod"}),
+	)
+	assert.Equal(t, []string{"admin", "dev"}, history.SortByRecent([]string{"admin", "dev"}, []string{}))
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"nextunit/op2aws/redact"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LEVEL_ERROR Level = iota
+	LEVEL_WARN
+	LEVEL_INFO
+	LEVEL_DEBUG
+)
+
+var (
+	LEVEL_NAMES = map[Level]string{
+		LEVEL_ERROR: "ERROR",
+		LEVEL_WARN:  "WARN",
+		LEVEL_INFO:  "INFO",
+		LEVEL_DEBUG: "DEBUG",
+	}
+
+	// Values of fields with these words inside of the key are never written.
+	SENSITIVE_KEYS = []string{"secret", "token", "otp", "password"}
+
+	defaultLogger = New(LEVEL_WARN, os.Stderr, nil)
+)
+
+// Logger writes the entries readable to the console and in the logfmt format to the file.
+type Logger struct {
+	mutex sync.Mutex
+
+	level   Level
+	console io.Writer
+	file    io.Writer
+	now     func() time.Time
+}
+
+func ParseLevel(level string) (Level, error) {
+	for l, name := range LEVEL_NAMES {
+		if strings.EqualFold(level, name) {
+			return l, nil
+		}
+	}
+
+	return LEVEL_ERROR, fmt.Errorf("The log level %s is not supported, use error, warn, info or debug", level)
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitiveKey := range SENSITIVE_KEYS {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// formatFields formats key value pairs like key=value. A key without a value gets an empty value.
+func formatFields(keyvals []interface{}) string {
+	fields := []string{}
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		value := ""
+		if i+1 < len(keyvals) {
+			value = fmt.Sprint(keyvals[i+1])
+		}
+
+		if isSensitive(key) {
+			value = redact.REDACTED
+		}
+
+		fields = append(fields, key+"="+formatValue(value))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if level > l.level {
+		return
+	}
+
+	msg = redact.String(msg)
+	fields := redact.String(formatFields(keyvals))
+	if fields != "" {
+		fields = " " + fields
+	}
+
+	if l.console != nil {
+		fmt.Fprintf(l.console, "%s %s%s\n", LEVEL_NAMES[level], msg, fields)
+	}
+
+	if l.file != nil {
+		fmt.Fprintf(l.file, "time=%s level=%s msg=%s%s\n", l.now().Format(time.RFC3339), LEVEL_NAMES[level], formatValue(msg), fields)
+	}
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LEVEL_DEBUG, msg, keyvals)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LEVEL_WARN, msg, keyvals)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LEVEL_INFO, msg, keyvals)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LEVEL_ERROR, msg, keyvals)
+}
+
+// New creates a logger for entries up to the level. The console or the file can be nil.
+func New(level Level, console io.Writer, file io.Writer) *Logger {
+	return &Logger{level: level, console: console, file: file, now: time.Now}
+}
+
+// SetDefault replaces the logger, that is used by the functions of the package.
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+func Debug(msg string, keyvals ...interface{}) {
+	defaultLogger.Debug(msg, keyvals...)
+}
+
+func Warn(msg string, keyvals ...interface{}) {
+	defaultLogger.Warn(msg, keyvals...)
+}
+
+func Info(msg string, keyvals ...interface{}) {
+	defaultLogger.Info(msg, keyvals...)
+}
+
+func Error(msg string, keyvals ...interface{}) {
+	defaultLogger.Error(msg, keyvals...)
+}
+
+// Since returns the duration since the start for the fields of an entry.
+func Since(start time.Time) string {
+	return time.Since(start).Round(time.Millisecond).String()
+}
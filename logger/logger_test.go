@@ -0,0 +1,84 @@
+package logger_test
+
+import (
+	"bytes"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/redact"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	console := &bytes.Buffer{}
+	l := logger.New(logger.LEVEL_INFO, console, nil)
+
+	l.Error("test error")
+	l.Warn("test warn")
+	l.Info("test info")
+	l.Debug("test debug")
+
+	assert.Equal("ERROR test error\nWARN test warn\nINFO test info\n", console.String(), "Entries above the level should not be written")
+}
+
+func TestFields(t *testing.T) {
+	assert := assert.New(t)
+
+	console := &bytes.Buffer{}
+	file := &bytes.Buffer{}
+	l := logger.New(logger.LEVEL_DEBUG, console, file)
+
+	l.Debug("Cache miss", "reason", "expired", "path", "/test path", "missing")
+
+	assert.Equal("DEBUG Cache miss reason=expired path=\"/test path\" missing=\"\"\n", console.String())
+	assert.Regexp("^time=[^ ]+ level=DEBUG msg=\"Cache miss\" reason=expired path=\"/test path\" missing=\"\"\n$", file.String())
+}
+
+func TestSensitiveFields(t *testing.T) {
+	console := &bytes.Buffer{}
+	l := logger.New(logger.LEVEL_DEBUG, console, nil)
+
+	l.Info("test", "SecretAccessKey", "test-secret", "session_token", "test-token", "otp", "123456", "vault", "test-vault")
+
+	assert.False(t, strings.Contains(console.String(), "test-secret"))
+	assert.False(t, strings.Contains(console.String(), "test-token"))
+	assert.False(t, strings.Contains(console.String(), "123456"))
+	assert.Contains(t, console.String(), "SecretAccessKey="+redact.REDACTED)
+	assert.Contains(t, console.String(), "vault=test-vault")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, expected := range map[string]logger.Level{
+		"error": logger.LEVEL_ERROR,
+		"warn":  logger.LEVEL_WARN,
+		"INFO":  logger.LEVEL_INFO,
+		"Debug": logger.LEVEL_DEBUG,
+	} {
+		level, err := logger.ParseLevel(name)
+		assert.Nil(err)
+		assert.Equal(expected, level)
+	}
+
+	_, err := logger.ParseLevel("trace")
+	assert.ErrorContains(err, "not supported")
+}
+
+func TestRegisteredSecrets(t *testing.T) {
+	redact.Reset()
+	defer redact.Reset()
+	redact.Add("test-secret")
+
+	console := &bytes.Buffer{}
+	file := &bytes.Buffer{}
+	l := logger.New(logger.LEVEL_DEBUG, console, file)
+
+	l.Error("Unable to use test-secret", "error", "invalid test-secret")
+
+	assert.Equal(t, "ERROR Unable to use [REDACTED] error=\"invalid [REDACTED]\"\n", console.String())
+	assert.NotContains(t, file.String(), "test-secret")
+}
@@ -0,0 +1,54 @@
+package opaws
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+type AccessKeyReport struct {
+	UserName        string
+	AccessKeyId     string
+	CreateDate      time.Time
+	LastUsedDate    *time.Time
+	LastUsedService string
+	MFAEnabled      bool
+}
+
+// GetAccessKeyReport describes the access key inside of the vault and the IAM user it belongs to.
+func (client OpAWS) GetAccessKeyReport() (*AccessKeyReport, error) {
+	accessKeyId, secretAccessKey, err := client.getVaultAccessKey()
+	if err != nil {
+		return nil, err
+	}
+
+	iamClient := client.generateIamClient(accessKeyId, secretAccessKey)
+	metadata, err := getAccessKeyMetadata(iamClient, accessKeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AccessKeyReport{
+		UserName:    aws.StringValue(metadata.UserName),
+		AccessKeyId: accessKeyId,
+		CreateDate:  *metadata.CreateDate,
+	}
+
+	lastUsed, err := iamClient.GetAccessKeyLastUsed(&iam.GetAccessKeyLastUsedInput{AccessKeyId: &accessKeyId})
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.AccessKeyLastUsed != nil {
+		report.LastUsedDate = lastUsed.AccessKeyLastUsed.LastUsedDate
+		report.LastUsedService = aws.StringValue(lastUsed.AccessKeyLastUsed.ServiceName)
+	}
+
+	mfaDevices, err := iamClient.ListMFADevices(&iam.ListMFADevicesInput{UserName: metadata.UserName})
+	if err != nil {
+		return nil, err
+	}
+	report.MFAEnabled = len(mfaDevices.MFADevices) != 0
+
+	return report, nil
+}
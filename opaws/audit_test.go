@@ -0,0 +1,97 @@
+package opaws_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/opaws"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	getAccessKeyLastUsedReturnValue *iam.GetAccessKeyLastUsedOutput
+	listMFADevicesReturnValue       *iam.ListMFADevicesOutput
+
+	listMFADevicesInput *iam.ListMFADevicesInput
+)
+
+func setupAuditTestCase() {
+	setupRotateTestCase()
+
+	listAccessKeysReturnValue.AccessKeyMetadata[1].UserName = aws.String("test-user")
+	getAccessKeyLastUsedReturnValue = &iam.GetAccessKeyLastUsedOutput{
+		AccessKeyLastUsed: &iam.AccessKeyLastUsed{
+			LastUsedDate: aws.Time(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)),
+			ServiceName:  aws.String("sts"),
+		},
+	}
+	listMFADevicesReturnValue = &iam.ListMFADevicesOutput{
+		MFADevices: []*iam.MFADevice{{SerialNumber: aws.String("test-mfa")}},
+	}
+
+	listMFADevicesInput = nil
+}
+
+func (iamApiTest) GetAccessKeyLastUsed(input *iam.GetAccessKeyLastUsedInput) (*iam.GetAccessKeyLastUsedOutput, error) {
+	if getAccessKeyLastUsedReturnValue == nil {
+		return nil, fmt.Errorf("Test error GetAccessKeyLastUsed")
+	}
+	return getAccessKeyLastUsedReturnValue, nil
+}
+
+func (iamApiTest) ListMFADevices(input *iam.ListMFADevicesInput) (*iam.ListMFADevicesOutput, error) {
+	listMFADevicesInput = input
+	if listMFADevicesReturnValue == nil {
+		return nil, fmt.Errorf("Test error ListMFADevices")
+	}
+	return listMFADevicesReturnValue, nil
+}
+
+func TestGetAccessKeyReport(t *testing.T) {
+	setupAuditTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	report, err := client.GetAccessKeyReport()
+
+	assert.Nil(err)
+	assert.Equal("test-user", report.UserName)
+	assert.Equal("access-key-id-default", report.AccessKeyId)
+	assert.Equal(*listAccessKeysReturnValue.AccessKeyMetadata[1].CreateDate, report.CreateDate)
+	assert.Equal(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), *report.LastUsedDate)
+	assert.Equal("sts", report.LastUsedService)
+	assert.True(report.MFAEnabled)
+	assert.Equal("test-user", *listMFADevicesInput.UserName)
+
+	listMFADevicesReturnValue = &iam.ListMFADevicesOutput{}
+	getAccessKeyLastUsedReturnValue = &iam.GetAccessKeyLastUsedOutput{}
+	report, err = client.GetAccessKeyReport()
+
+	assert.Nil(err)
+	assert.Nil(report.LastUsedDate, "A key, that has never been used, has no last use")
+	assert.False(report.MFAEnabled)
+}
+
+func TestGetAccessKeyReportErrors(t *testing.T) {
+	for name, setup := range map[string]func(){
+		"vault":          func() { getAccessKeyIdReturnValue = nil },
+		"list keys":      func() { listAccessKeysReturnValue = nil },
+		"last used":      func() { getAccessKeyLastUsedReturnValue = nil },
+		"list mfa":       func() { listMFADevicesReturnValue = nil },
+		"key not listed": func() { getAccessKeyIdReturnValue = aws.String("unknown-access-key-id") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupAuditTestCase()
+			setup()
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			report, err := client.GetAccessKeyReport()
+
+			assert.NotNil(t, err)
+			assert.Nil(t, report)
+		})
+	}
+}
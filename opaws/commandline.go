@@ -0,0 +1,194 @@
+package opaws
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const OS_WINDOWS = "windows"
+
+var (
+	// The operating system, whose quoting is used for the credential_process.
+	CREDENTIAL_PROCESS_OS = runtime.GOOS
+
+	POSIX_SAFE_PATTERN   = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+	WINDOWS_SAFE_PATTERN = regexp.MustCompile(`^[^\s"&|<>^()%!]+$`)
+)
+
+// quotePosix quotes the argument with single quotes, if it contains characters with a meaning for the shell.
+func quotePosix(arg string) string {
+	if POSIX_SAFE_PATTERN.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// quoteWindows quotes the argument with the rules of CommandLineToArgvW.
+func quoteWindows(arg string) string {
+	if WINDOWS_SAFE_PATTERN.MatchString(arg) {
+		return arg
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for _, r := range arg {
+		switch r {
+		case '\\':
+			slashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// JoinCommandLine quotes every argument for the command line of the operating system.
+func JoinCommandLine(args []string, goos string) string {
+	quote := quotePosix
+	if goos == OS_WINDOWS {
+		quote = quoteWindows
+	}
+
+	quoted := []string{}
+	for _, arg := range args {
+		quoted = append(quoted, quote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// SplitCommandLine splits the command line into the arguments with the rules of the operating system.
+func SplitCommandLine(line, goos string) ([]string, error) {
+	if goos == OS_WINDOWS {
+		return splitWindowsCommandLine(line)
+	}
+
+	return splitPosixCommandLine(line)
+}
+
+// splitPosixCommandLine splits a command line with single quotes, double quotes and backslash escapes.
+func splitPosixCommandLine(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			}
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated quote in command line: %s", line)
+	}
+
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// splitWindowsCommandLine splits a command line with the rules of CommandLineToArgvW.
+func splitWindowsCommandLine(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inWord := false
+	inQuote := false
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\':
+			slashes := 0
+			for i < len(runes) && runes[i] == '\\' {
+				slashes++
+				i++
+			}
+
+			if i < len(runes) && runes[i] == '"' {
+				current.WriteString(strings.Repeat(`\`, slashes/2))
+				if slashes%2 == 1 {
+					current.WriteRune('"')
+				} else {
+					inQuote = !inQuote
+				}
+			} else {
+				current.WriteString(strings.Repeat(`\`, slashes))
+				i--
+			}
+			inWord = true
+		case r == '"':
+			inQuote = !inQuote
+			inWord = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("Unterminated quote in command line: %s", line)
+	}
+
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
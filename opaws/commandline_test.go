@@ -0,0 +1,90 @@
+package opaws_test
+
+import (
+	"nextunit/op2aws/opaws"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var COMMAND_LINE_OS = []string{"linux", "darwin", opaws.OS_WINDOWS}
+
+func setCredentialProcessOS(t *testing.T, goos string) {
+	previous := opaws.CREDENTIAL_PROCESS_OS
+	opaws.CREDENTIAL_PROCESS_OS = goos
+	t.Cleanup(func() {
+		opaws.CREDENTIAL_PROCESS_OS = previous
+	})
+}
+
+func TestJoinCommandLine(t *testing.T) {
+	args := []string{"op2aws", "cli", "my vault", "it's $HOME", `C:\dir\`, `say "hi"`, ""}
+
+	assert.Equal(t, `op2aws cli 'my vault' 'it'"'"'s $HOME' 'C:\dir\' 'say "hi"' ''`, opaws.JoinCommandLine(args, "linux"))
+	assert.Equal(t, `op2aws cli "my vault" "it's $HOME" C:\dir\ "say \"hi\"" ""`, opaws.JoinCommandLine(args, opaws.OS_WINDOWS))
+	assert.Equal(t, `"a&b" "C:\my dir\\" "a\\\"b"`, opaws.JoinCommandLine([]string{"a&b", `C:\my dir\`, `a\"b`}, opaws.OS_WINDOWS))
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	args, err := opaws.SplitCommandLine(`a\\\"b "c d" e\f "g\\" ""`, opaws.OS_WINDOWS)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`a\"b`, "c d", `e\f`, `g\`, ""}, args)
+
+	_, err = opaws.SplitCommandLine(`"a b`, opaws.OS_WINDOWS)
+	assert.NotNil(t, err)
+
+	_, err = opaws.SplitCommandLine(`'a b`, "linux")
+	assert.NotNil(t, err)
+}
+
+func TestCommandLineRoundTrip(t *testing.T) {
+	for _, goos := range COMMAND_LINE_OS {
+		t.Run(goos, func(t *testing.T) {
+			err := quick.Check(func(args []string) bool {
+				split, err := opaws.SplitCommandLine(opaws.JoinCommandLine(args, goos), goos)
+				if err != nil {
+					return false
+				}
+
+				return len(args) == 0 && len(split) == 0 || reflect.DeepEqual(args, split)
+			}, &quick.Config{MaxCount: 1000})
+
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestCredentialProcessRoundTrip(t *testing.T) {
+	for _, goos := range COMMAND_LINE_OS {
+		t.Run(goos, func(t *testing.T) {
+			setCredentialProcessOS(t, goos)
+
+			err := quick.Check(func(vault, item, role string) bool {
+				profile := &opaws.OpProfile{Vault: vault, Item: item, AssumeRole: role, MFA: opaws.MFA_AUTO}
+
+				parsed, err := opaws.ParseCredentialProcess(profile.GetCredentialProcess())
+				if err != nil {
+					return false
+				}
+
+				// An empty role is not written.
+				return parsed.Vault == vault && parsed.Item == item && parsed.AssumeRole == role && parsed.MFA == opaws.MFA_AUTO
+			}, &quick.Config{MaxCount: 1000})
+
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestCredentialProcessWithDashedNames(t *testing.T) {
+	setCredentialProcessOS(t, "linux")
+
+	profile := &opaws.OpProfile{Vault: "-vault", Item: "--item", MFA: "testMfa"}
+	assert.Equal(t, "op2aws cli -m testMfa -- -vault --item", profile.GetCredentialProcess())
+
+	parsed, err := opaws.ParseCredentialProcess(profile.GetCredentialProcess())
+	assert.Nil(t, err)
+	assert.Equal(t, profile, parsed)
+}
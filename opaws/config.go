@@ -7,6 +7,7 @@ import (
 	"nextunit/op2aws/awsvault"
 	"nextunit/op2aws/config"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -20,6 +21,9 @@ type AwsConfigInterface interface {
 	IsNotExist(err error) bool
 	WriteFile(filename string, data []byte, perm fs.FileMode) error
 	OpenFile(name string, flag int, perm fs.FileMode) (AwsConfigFileInterface, error)
+	ReadFile(filename string) ([]byte, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	ReplaceFile(filename string, data []byte, perm fs.FileMode) error
 }
 
 type AwsConfigFileInterface interface {
@@ -32,8 +36,15 @@ type AwsConfigClientDefault struct {
 }
 
 var (
-	PROFILE_TEMPLATE = "\n\n[profile %s]\n    credential_process = sh -c '\"%s\" \"%s\" \"%s\" \"%s\"%s'"
-	AWS_FILE_PATH    = fmt.Sprintf("%s/.aws/config", os.Getenv("HOME"))
+	PROFILE_TEMPLATE         = "\n\n[profile %s]\n    credential_process = %s"
+	CHAINED_PROFILE_TEMPLATE = "\n\n[profile %s]\n    credential_process = %s\n    " + KEY_SOURCE_PROFILE + " = %s\n    " + KEY_ROLE_ARN + " = %s"
+	AWS_FILE_PATH            = fmt.Sprintf("%s/.aws/config", os.Getenv("HOME"))
+	CONFIG_DIR_MODE          = fs.FileMode(0700)
+
+	// The keys of a chained profile, which only op2aws reads.
+	KEY_SOURCE_PROFILE = "op2aws_source_profile"
+	KEY_ROLE_ARN       = "op2aws_role_arn"
+	KEY_MFA_SERIAL     = "op2aws_mfa_serial"
 )
 
 func (AwsConfigClientDefault) Stat(name string) (fs.FileInfo, error) {
@@ -52,50 +63,148 @@ func (AwsConfigClientDefault) OpenFile(name string, flag int, perm fs.FileMode)
 	return os.OpenFile(name, flag, perm)
 }
 
+func (AwsConfigClientDefault) ReadFile(filename string) ([]byte, error) {
+	return ioutil.ReadFile(filename)
+}
+
+func (AwsConfigClientDefault) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (AwsConfigClientDefault) ReplaceFile(filename string, data []byte, perm fs.FileMode) error {
+	return config.ReplaceFile(filename, data, perm)
+}
+
+func (c AWSConfig) read() (*iniFile, error) {
+	content, err := c.client.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIni(string(content)), nil
+}
+
+func (c AWSConfig) write(file *iniFile, perm fs.FileMode) error {
+	if err := c.client.ReplaceFile(c.path, []byte(file.String()), perm); err != nil {
+		return fmt.Errorf("%w: %w", ErrConfigWrite, err)
+	}
+
+	return nil
+}
+
 func (c AWSConfig) GetPath() string {
 	return c.path
 }
 
 func GetProfileBody(profileName, vault, item, assumeRole, mfa, labelAccessKey, labelSecretAccessKey string) string {
-	additionalOptionsArray := []string{}
+	profile := &OpProfile{
+		Name:                 profileName,
+		Vault:                vault,
+		Item:                 item,
+		AssumeRole:           assumeRole,
+		MFA:                  mfa,
+		LabelAccessKey:       labelAccessKey,
+		LabelSecretAccessKey: labelSecretAccessKey,
+	}
+
+	return profile.GetBody()
+}
+
+// GetChainedProfileBody returns the profile section, that assumes the role with the credentials of the source profile.
+func GetChainedProfileBody(profileName, sourceProfile, assumeRole, mfa string) string {
+	profile := &OpProfile{
+		Name:          profileName,
+		AssumeRole:    assumeRole,
+		MFA:           mfa,
+		SourceProfile: sourceProfile,
+	}
+
+	return profile.GetBody()
+}
+
+// getKeys returns the keys of the profile section in the order of the section. Keys with an empty value are not set.
+func (p OpProfile) getKeys() [][2]string {
+	if p.SourceProfile == "" {
+		return [][2]string{{"credential_process", p.GetCredentialProcess()}, {KEY_SOURCE_PROFILE, ""}, {KEY_ROLE_ARN, ""}, {KEY_MFA_SERIAL, ""}}
+	}
+
+	return [][2]string{{"credential_process", p.GetCredentialProcess()}, {KEY_SOURCE_PROFILE, p.SourceProfile}, {KEY_ROLE_ARN, p.AssumeRole}, {KEY_MFA_SERIAL, p.MFA}}
+}
+
+// GetBody returns the profile section for the config file.
+func (p OpProfile) GetBody() string {
+	if p.SourceProfile == "" {
+		return fmt.Sprintf(PROFILE_TEMPLATE, p.Name, p.GetCredentialProcess())
+	}
+
+	body := fmt.Sprintf(CHAINED_PROFILE_TEMPLATE, p.Name, p.GetCredentialProcess(), p.SourceProfile, p.AssumeRole)
+	if p.MFA != "" {
+		body += "\n    " + KEY_MFA_SERIAL + " = " + p.MFA
+	}
+
+	return body
+}
+
+// GetCredentialProcess returns the value of the credential_process, that calls op2aws with the values of the profile.
+func (p OpProfile) GetCredentialProcess() string {
+	args := []string{config.COMMAND_ROOT, config.COMMAND_CLI}
+	if p.SourceProfile != "" {
+		return JoinCommandLine(append(args, "--profile", p.Name), CREDENTIAL_PROCESS_OS)
+	}
+
+	// Names starting with a dash would be read as flags, so they are put behind the flags and --.
+	dashed := strings.HasPrefix(p.Vault, "-") || strings.HasPrefix(p.Item, "-")
+	if !dashed {
+		args = append(args, p.Vault, p.Item)
+	}
+
+	if p.AssumeRole != "" {
+		args = append(args, "-a", p.AssumeRole)
+	}
+
+	if p.MFA != "" {
+		args = append(args, "-m", p.MFA)
+	}
+
+	if p.MFASession {
+		args = append(args, "--mfa-session")
+	}
 
-	if assumeRole != "" {
-		additionalOptionsArray = append(additionalOptionsArray, fmt.Sprintf("\"-a\" \"%s\"", assumeRole))
+	if p.LabelAccessKey != awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT && p.LabelAccessKey != "" {
+		args = append(args, "-k", p.LabelAccessKey)
 	}
 
-	if mfa != "" {
-		additionalOptionsArray = append(additionalOptionsArray, fmt.Sprintf("\"-m\" \"%s\"", mfa))
+	if p.LabelSecretAccessKey != awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT && p.LabelSecretAccessKey != "" {
+		args = append(args, "-s", p.LabelSecretAccessKey)
 	}
 
-	if labelAccessKey != awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT && labelAccessKey != "" {
-		additionalOptionsArray = append(additionalOptionsArray, fmt.Sprintf("\"-k\" \"%s\"", labelAccessKey))
+	if p.Preset != "" {
+		args = append(args, "--preset", p.Preset)
 	}
 
-	if labelSecretAccessKey != awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT && labelSecretAccessKey != "" {
-		additionalOptionsArray = append(additionalOptionsArray, fmt.Sprintf("\"-s\" \"%s\"", labelSecretAccessKey))
+	// The AWS CLI doesn't tell the credential_process its profile.
+	if p.Name != "" {
+		args = append(args, "--profile-name", p.Name)
 	}
 
-	additionalOptions := strings.TrimSpace(strings.Join(additionalOptionsArray[:], " "))
-	if len(additionalOptions) > 0 {
-		additionalOptions = " " + additionalOptions
+	if dashed {
+		args = append(args, "--", p.Vault, p.Item)
 	}
 
-	return fmt.Sprintf(
-		PROFILE_TEMPLATE,
-		profileName,
-		config.COMMAND_ROOT,
-		config.COMMAND_CLI,
-		vault,
-		item,
-		additionalOptions,
-	)
+	return JoinCommandLine(args, CREDENTIAL_PROCESS_OS)
 }
 
 func (c AWSConfig) WriteProfile(body string) error {
 	_, err := c.client.Stat(c.path)
 	if err != nil {
 		if c.client.IsNotExist(err) {
-			err = c.client.WriteFile(c.path, []byte(body), 0644)
+			err = c.client.MkdirAll(filepath.Dir(c.path), CONFIG_DIR_MODE)
+			if err == nil {
+				err = c.client.WriteFile(c.path, []byte(body), 0644)
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrConfigWrite, err)
+			}
 			return nil
 		} else {
 			return err
@@ -104,11 +213,11 @@ func (c AWSConfig) WriteProfile(body string) error {
 
 	file, err := c.client.OpenFile(c.path, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrConfigWrite, err)
 	}
 	defer file.Close()
 	if _, err := file.WriteString(body); err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrConfigWrite, err)
 	}
 	return nil
 }
@@ -54,6 +54,8 @@ var (
 	openFileReturnValue        opaws.AwsConfigFileInterface
 	closeReturnValue           error
 	writeStringReturnValue     int
+	readFileReturnValue        []byte
+	mkdirAllReturnValue        error
 
 	fileInfoCallCount        int
 	errorIsNotExistCallCount int
@@ -61,12 +63,16 @@ var (
 	openFileCallCount        int
 	closeCallCount           int
 	writeStringCallCount     int
+	readFileCallCount        int
+	mkdirAllCallCount        int
 
 	fileInfoInput        string
 	errorIsNotExistInput error
 	writeFileInput       []writeFileInputModel
 	openFileInput        []openFileInputModel
 	writeStringInput     string
+	readFileInput        string
+	mkdirAllInput        string
 
 	testCases = []testGetProfileInput{
 		{
@@ -77,7 +83,7 @@ var (
 			mfa:                  "testMfa",
 			labelAccessKey:       "testLabelAccessKey",
 			labelSecretAccessKey: "testLabelSecretAccessKey",
-			expectedOutput:       "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\" \"-a\" \"testAssumeRole\" \"-m\" \"testMfa\" \"-k\" \"testLabelAccessKey\" \"-s\" \"testLabelSecretAccessKey\"'",
+			expectedOutput:       "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -a testAssumeRole -m testMfa -k testLabelAccessKey -s testLabelSecretAccessKey --profile-name test-profile",
 		},
 		{
 			profileName:          "test-profile",
@@ -87,7 +93,7 @@ var (
 			mfa:                  "testMfa",
 			labelAccessKey:       awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT,
 			labelSecretAccessKey: awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT,
-			expectedOutput:       "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\" \"-a\" \"testAssumeRole\" \"-m\" \"testMfa\"'",
+			expectedOutput:       "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -a testAssumeRole -m testMfa --profile-name test-profile",
 		},
 		{
 			profileName:    "test-profile",
@@ -95,27 +101,27 @@ var (
 			item:           "test-item",
 			assumeRole:     "testAssumeRole",
 			mfa:            "testMfa",
-			expectedOutput: "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\" \"-a\" \"testAssumeRole\" \"-m\" \"testMfa\"'",
+			expectedOutput: "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -a testAssumeRole -m testMfa --profile-name test-profile",
 		},
 		{
 			profileName:    "test-profile",
 			vault:          "test-vault",
 			item:           "test-item",
 			assumeRole:     "testAssumeRole",
-			expectedOutput: "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\" \"-a\" \"testAssumeRole\"'",
+			expectedOutput: "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -a testAssumeRole --profile-name test-profile",
 		},
 		{
 			profileName:    "test-profile",
 			vault:          "test-vault",
 			item:           "test-item",
 			mfa:            "testMfa",
-			expectedOutput: "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\" \"-m\" \"testMfa\"'",
+			expectedOutput: "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -m testMfa --profile-name test-profile",
 		},
 		{
 			profileName:    "test-profile",
 			vault:          "test-vault",
 			item:           "test-item",
-			expectedOutput: "\n\n[profile test-profile]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"test-vault\" \"test-item\"'",
+			expectedOutput: "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item --profile-name test-profile",
 		},
 	}
 )
@@ -128,6 +134,8 @@ func setupTestCases() {
 	openFileReturnValue = &awsConfigFileMock{}
 	closeReturnValue = nil
 	writeStringReturnValue = 2
+	readFileReturnValue = []byte{}
+	mkdirAllReturnValue = nil
 
 	fileInfoCallCount = 0
 	errorIsNotExistCallCount = 0
@@ -135,12 +143,16 @@ func setupTestCases() {
 	openFileCallCount = 0
 	closeCallCount = 0
 	writeStringCallCount = 0
+	readFileCallCount = 0
+	mkdirAllCallCount = 0
 
 	fileInfoInput = ""
 	errorIsNotExistInput = nil
 	writeFileInput = []writeFileInputModel{}
 	openFileInput = []openFileInputModel{}
 	writeStringInput = ""
+	readFileInput = ""
+	mkdirAllInput = ""
 }
 
 func (awsConfigFileMock) Close() error {
@@ -158,6 +170,10 @@ func (awsConfigFileMock) WriteString(s string) (n int, err error) {
 	return writeStringReturnValue, nil
 }
 
+func (testAwsFileInfoMock) Mode() fs.FileMode {
+	return 0640
+}
+
 func (testAwsConfigMock) Stat(name string) (fs.FileInfo, error) {
 	fileInfoCallCount++
 	fileInfoInput = name
@@ -201,6 +217,34 @@ func (testAwsConfigMock) OpenFile(name string, flag int, perm fs.FileMode) (opaw
 	return openFileReturnValue, nil
 }
 
+func (testAwsConfigMock) ReadFile(filename string) ([]byte, error) {
+	readFileCallCount++
+	readFileInput = filename
+	if readFileReturnValue == nil {
+		return nil, fmt.Errorf("test error ReadFile")
+	}
+
+	return readFileReturnValue, nil
+}
+
+func (testAwsConfigMock) MkdirAll(path string, perm fs.FileMode) error {
+	mkdirAllCallCount++
+	mkdirAllInput = path
+
+	return mkdirAllReturnValue
+}
+
+func (testAwsConfigMock) ReplaceFile(filename string, data []byte, perm fs.FileMode) error {
+	writeFileCallCount++
+	writeFileInput = append(writeFileInput, writeFileInputModel{
+		filename: filename,
+		data:     data,
+		perm:     perm,
+	})
+
+	return writeFileReturnValue
+}
+
 func TestGetProfileBody(t *testing.T) {
 	t.Helper()
 
@@ -221,6 +265,26 @@ func TestGetProfileBody(t *testing.T) {
 	}
 }
 
+func TestWriteProfileFileNotExisting(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	fileInfoReturnValue = nil
+	errorIsNotExistReturnValue = true
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-dir/test-path")
+	err := client.WriteProfile("test-body")
+
+	assert.Nil(err)
+	assert.Equal("test-dir", mkdirAllInput, "The directory of the config file should be created")
+	assert.Equal(1, writeFileCallCount)
+	assert.Equal("test-body", string(writeFileInput[0].data))
+
+	mkdirAllReturnValue = fmt.Errorf("test error MkdirAll")
+	err = client.WriteProfile("test-body")
+	assert.ErrorIs(err, opaws.ErrConfigWrite)
+	assert.Equal(1, writeFileCallCount, "The file should not be written without its directory")
+}
+
 func TestWriteProfileFileExists(t *testing.T) {
 	assert := assert.New(t)
 	t.Helper()
@@ -0,0 +1,112 @@
+package opaws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"nextunit/op2aws/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+var (
+	FEDERATION_ENDPOINT          = "https://signin.aws.amazon.com/federation"
+	CONSOLE_URL                  = "https://console.aws.amazon.com/"
+	CONSOLE_ISSUER               = config.COMMAND_ROOT
+	CONSOLE_SESSION_DURATION_MIN = 15 * time.Minute
+	CONSOLE_SESSION_DURATION_MAX = 12 * time.Hour
+	// CONSOLE_HTTP_TIMEOUT limits the request to the federation endpoint.
+	CONSOLE_HTTP_TIMEOUT = 30 * time.Second
+)
+
+type federationSession struct {
+	SessionId    string `json:"sessionId"`
+	SessionKey   string `json:"sessionKey"`
+	SessionToken string `json:"sessionToken"`
+}
+
+type federationSigninToken struct {
+	SigninToken string `json:"SigninToken"`
+}
+
+func getConsoleDestination(destination string) string {
+	if strings.HasPrefix(destination, "https://") {
+		return destination
+	}
+
+	return CONSOLE_URL + strings.TrimPrefix(destination, "/")
+}
+
+func getSigninToken(endpoint string, credentials *sts.Credentials, duration time.Duration) (string, error) {
+	session, err := json.Marshal(federationSession{
+		SessionId:    *credentials.AccessKeyId,
+		SessionKey:   *credentials.SecretAccessKey,
+		SessionToken: *credentials.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+	if duration != 0 {
+		query.Set("SessionDuration", strconv.Itoa(int(duration.Seconds())))
+	}
+
+	client := &http.Client{Timeout: CONSOLE_HTTP_TIMEOUT}
+	response, err := client.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("The federation endpoint returned %s. Console sign-in is only possible with credentials of an assumed role", response.Status)
+	}
+
+	token := &federationSigninToken{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return "", err
+	}
+
+	if token.SigninToken == "" {
+		return "", fmt.Errorf("The federation endpoint returned no sign-in token")
+	}
+
+	return token.SigninToken, nil
+}
+
+// GetConsoleUrl returns the sign-in URL of the AWS console for the credentials of an assumed role.
+func GetConsoleUrl(endpoint string, credentials *sts.Credentials, destination string, duration time.Duration) (string, error) {
+	if credentials.SessionToken == nil {
+		return "", fmt.Errorf("Console sign-in is only possible with temporary credentials of an assumed role")
+	}
+
+	if duration != 0 && (duration < CONSOLE_SESSION_DURATION_MIN || duration > CONSOLE_SESSION_DURATION_MAX) {
+		return "", fmt.Errorf("The console session duration must be between %s and %s", CONSOLE_SESSION_DURATION_MIN, CONSOLE_SESSION_DURATION_MAX)
+	}
+
+	token, err := getSigninToken(endpoint, credentials, duration)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "login")
+	query.Set("Issuer", CONSOLE_ISSUER)
+	query.Set("Destination", getConsoleDestination(destination))
+	query.Set("SigninToken", token)
+
+	return endpoint + "?" + query.Encode(), nil
+}
@@ -0,0 +1,132 @@
+package opaws_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"nextunit/op2aws/opaws"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	federationStatusReturnValue int
+	federationBodyReturnValue   string
+
+	federationCallCount int
+
+	federationInput url.Values
+)
+
+func setupFederationTestCase() *httptest.Server {
+	federationStatusReturnValue = http.StatusOK
+	federationBodyReturnValue = "{\"SigninToken\":\"test-signin-token\"}"
+
+	federationCallCount = 0
+
+	federationInput = nil
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		federationCallCount++
+		federationInput = r.URL.Query()
+
+		w.WriteHeader(federationStatusReturnValue)
+		w.Write([]byte(federationBodyReturnValue))
+	}))
+}
+
+func getConsoleTestCredentials() *sts.Credentials {
+	return &sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+	}
+}
+
+func TestGetConsoleUrl(t *testing.T) {
+	assert := assert.New(t)
+	ts := setupFederationTestCase()
+	defer ts.Close()
+
+	consoleUrl, err := opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "ec2/home", time.Hour)
+	assert.Nil(err)
+	assert.Equal(1, federationCallCount)
+
+	assert.Equal("getSigninToken", federationInput.Get("Action"))
+	assert.Equal("3600", federationInput.Get("SessionDuration"))
+
+	var session map[string]string
+	assert.Nil(json.Unmarshal([]byte(federationInput.Get("Session")), &session))
+	assert.Equal(map[string]string{
+		"sessionId":    "access-key-id",
+		"sessionKey":   "secret-access-key",
+		"sessionToken": "session-token",
+	}, session)
+
+	parsedUrl, err := url.Parse(consoleUrl)
+	assert.Nil(err)
+	assert.Equal(ts.URL, parsedUrl.Scheme+"://"+parsedUrl.Host)
+	assert.Equal("login", parsedUrl.Query().Get("Action"))
+	assert.Equal(opaws.CONSOLE_ISSUER, parsedUrl.Query().Get("Issuer"))
+	assert.Equal("https://console.aws.amazon.com/ec2/home", parsedUrl.Query().Get("Destination"))
+	assert.Equal("test-signin-token", parsedUrl.Query().Get("SigninToken"))
+}
+
+func TestGetConsoleUrlWithoutDuration(t *testing.T) {
+	ts := setupFederationTestCase()
+	defer ts.Close()
+
+	consoleUrl, err := opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "https://eu-central-1.console.aws.amazon.com/s3/home", 0)
+	assert.Nil(t, err)
+	assert.False(t, federationInput.Has("SessionDuration"))
+
+	parsedUrl, _ := url.Parse(consoleUrl)
+	assert.Equal(t, "https://eu-central-1.console.aws.amazon.com/s3/home", parsedUrl.Query().Get("Destination"))
+}
+
+func TestGetConsoleUrlErrors(t *testing.T) {
+	ts := setupFederationTestCase()
+	defer ts.Close()
+
+	credentials := getConsoleTestCredentials()
+	credentials.SessionToken = nil
+	_, err := opaws.GetConsoleUrl(ts.URL, credentials, "", 0)
+	assert.ErrorContains(t, err, "temporary credentials")
+
+	_, err = opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "", time.Minute)
+	assert.ErrorContains(t, err, "must be between")
+	assert.Equal(t, 0, federationCallCount)
+
+	federationStatusReturnValue = http.StatusBadRequest
+	_, err = opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "", 0)
+	assert.ErrorContains(t, err, "400")
+
+	federationStatusReturnValue = http.StatusOK
+	federationBodyReturnValue = "{}"
+	_, err = opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "", 0)
+	assert.ErrorContains(t, err, "no sign-in token")
+}
+
+func TestGetConsoleUrlTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	timeout := opaws.CONSOLE_HTTP_TIMEOUT
+	opaws.CONSOLE_HTTP_TIMEOUT = 50 * time.Millisecond
+	defer func() { opaws.CONSOLE_HTTP_TIMEOUT = timeout }()
+
+	start := time.Now()
+	_, err := opaws.GetConsoleUrl(ts.URL, getConsoleTestCredentials(), "", 0)
+
+	assert.NotNil(t, err, "A hanging federation endpoint should time out")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,97 @@
+package opaws
+
+import (
+	"fmt"
+	"nextunit/op2aws/config"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+var (
+	AWS_CREDENTIALS_FILE_PATH = fmt.Sprintf("%s/.aws/credentials", os.Getenv("HOME"))
+	CREDENTIALS_EXPIRES_KEY   = "x_security_token_expires"
+	// Marks the sections of op2aws. Other tools write x_security_token_expires as well.
+	CREDENTIALS_MANAGED_KEY = "x_op2aws_managed"
+)
+
+func (c AWSConfig) readCredentialsFile() (*iniFile, error) {
+	file, err := c.read()
+	if err != nil && c.client.IsNotExist(err) {
+		return parseIni(""), nil
+	}
+
+	return file, err
+}
+
+func isOp2awsCredentialsSection(section *iniSection) bool {
+	if section == nil {
+		return true
+	}
+
+	value, _ := section.get(CREDENTIALS_MANAGED_KEY)
+	return value == "true"
+}
+
+// WriteCredentials writes the temporary credentials as profile into the credentials file. Other credentials are never
+// overwritten.
+func (c AWSConfig) WriteCredentials(profileName string, credentials *sts.Credentials) error {
+	file, err := c.readCredentialsFile()
+	if err != nil {
+		return err
+	}
+
+	if !isOp2awsCredentialsSection(file.section(profileName)) {
+		return fmt.Errorf("The profile %s inside of %s contains credentials, that have not been written by %s", profileName, c.path, config.COMMAND_ROOT)
+	}
+
+	file.setSection(profileName, []string{
+		"aws_access_key_id = " + *credentials.AccessKeyId,
+		"aws_secret_access_key = " + *credentials.SecretAccessKey,
+		"aws_session_token = " + *credentials.SessionToken,
+		CREDENTIALS_EXPIRES_KEY + " = " + credentials.Expiration.UTC().Format(time.RFC3339),
+		CREDENTIALS_MANAGED_KEY + " = true",
+	})
+
+	return c.write(file, 0600)
+}
+
+// GetCredentialsProfiles returns the profiles of the credentials file, that op2aws has written.
+func (c AWSConfig) GetCredentialsProfiles() ([]string, error) {
+	file, err := c.readCredentialsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, section := range file.sections {
+		if section.name == "" || file.section(section.name) != section || !isOp2awsCredentialsSection(section) {
+			continue
+		}
+
+		names = append(names, section.name)
+	}
+
+	return names, nil
+}
+
+// RemoveCredentials removes the profile from the credentials file, when it has been written by op2aws.
+func (c AWSConfig) RemoveCredentials(profileName string) error {
+	file, err := c.readCredentialsFile()
+	if err != nil {
+		return err
+	}
+
+	section := file.section(profileName)
+	if section == nil {
+		return fmt.Errorf("The profile %s does not exist in %s", profileName, c.path)
+	}
+
+	if !isOp2awsCredentialsSection(section) {
+		return fmt.Errorf("The profile %s inside of %s contains credentials, that have not been written by %s", profileName, c.path, config.COMMAND_ROOT)
+	}
+
+	file.removeSection(profileName)
+	return c.write(file, 0600)
+}
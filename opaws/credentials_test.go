@@ -0,0 +1,166 @@
+package opaws_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/opaws"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+func getCredentialsFileTestCredentials() *sts.Credentials {
+	return &sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      aws.Time(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)),
+	}
+}
+
+func TestWriteCredentials(t *testing.T) {
+	testCasesWriteCredentials := []struct {
+		content        string
+		expectedOutput string
+	}{
+		{
+			content:        "",
+			expectedOutput: "[test-profile]\naws_access_key_id = access-key-id\naws_secret_access_key = secret-access-key\naws_session_token = session-token\nx_security_token_expires = 2023-05-01T12:00:00Z\nx_op2aws_managed = true\n",
+		},
+		{
+			content:        "[default]\naws_access_key_id = static\n",
+			expectedOutput: "[default]\naws_access_key_id = static\n\n[test-profile]\naws_access_key_id = access-key-id\naws_secret_access_key = secret-access-key\naws_session_token = session-token\nx_security_token_expires = 2023-05-01T12:00:00Z\nx_op2aws_managed = true\n",
+		},
+		{
+			content:        "[test-profile]\naws_access_key_id = old\nx_security_token_expires = 2023-04-01T12:00:00Z\nx_op2aws_managed = true\n\n[other]\naws_access_key_id = static\n",
+			expectedOutput: "[test-profile]\naws_access_key_id = access-key-id\naws_secret_access_key = secret-access-key\naws_session_token = session-token\nx_security_token_expires = 2023-05-01T12:00:00Z\nx_op2aws_managed = true\n\n[other]\naws_access_key_id = static\n",
+		},
+	}
+
+	for i, v := range testCasesWriteCredentials {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			assert := assert.New(t)
+			setupTestCases()
+			readFileReturnValue = []byte(v.content)
+
+			client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+			err := client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+
+			assert.Nil(err)
+			assert.Equal(1, writeFileCallCount, "client.WriteFile should be called one time")
+			assert.Equal("test-path", writeFileInput[0].filename)
+			assert.Equal(v.expectedOutput, string(writeFileInput[0].data))
+			assert.Equal(0600, int(writeFileInput[0].perm))
+		})
+	}
+}
+
+func TestWriteCredentialsWithoutDirectory(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), ".aws", "credentials")
+
+	client := opaws.NewAwsConfig(&opaws.AwsConfigClientDefault{}, path)
+	err := client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+	assert.Nil(err)
+
+	info, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(0600, int(info.Mode().Perm()))
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.Nil(err)
+	assert.Len(entries, 1, "No temporary file should be left")
+}
+
+func TestWriteCredentialsFileNotExisting(t *testing.T) {
+	setupTestCases()
+	readFileReturnValue = nil
+	errorIsNotExistReturnValue = true
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+	err := client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, writeFileCallCount)
+}
+
+func TestCredentialsOfOtherTools(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[test-profile]\naws_access_key_id = saml\nx_security_token_expires = 2023-05-01T12:00:00Z\n")
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+	err := client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+	assert.ErrorContains(err, "have not been written by op2aws", "Sections of e.g. saml2aws have x_security_token_expires as well")
+
+	err = client.RemoveCredentials("test-profile")
+	assert.ErrorContains(err, "have not been written by op2aws")
+
+	names, err := client.GetCredentialsProfiles()
+	assert.Nil(err)
+	assert.Empty(names)
+	assert.Equal(0, writeFileCallCount)
+}
+
+func TestWriteCredentialsErrors(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[test-profile]\naws_access_key_id = static\n")
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+	err := client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+	assert.ErrorContains(err, "have not been written by op2aws")
+	assert.Equal(0, writeFileCallCount, "Static credentials should never be overwritten")
+
+	readFileReturnValue = []byte("")
+	writeFileReturnValue = fmt.Errorf("test error WriteFile")
+	err = client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+	assert.ErrorContains(err, "test error WriteFile")
+	assert.ErrorIs(err, opaws.ErrConfigWrite)
+
+	readFileReturnValue = nil
+	err = client.WriteCredentials("test-profile", getCredentialsFileTestCredentials())
+	assert.ErrorContains(err, "test error ReadFile")
+	assert.NotErrorIs(err, opaws.ErrConfigWrite)
+}
+
+func TestRemoveCredentials(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[default]\naws_access_key_id = static\n\n[test-profile]\naws_access_key_id = access-key-id\nx_security_token_expires = 2023-05-01T12:00:00Z\nx_op2aws_managed = true\n\n[other]\naws_access_key_id = static\n")
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+	err := client.RemoveCredentials("test-profile")
+
+	assert.Nil(err)
+	assert.Equal("[default]\naws_access_key_id = static\n\n[other]\naws_access_key_id = static\n", string(writeFileInput[0].data))
+
+	err = client.RemoveCredentials("default")
+	assert.ErrorContains(err, "have not been written by op2aws")
+
+	err = client.RemoveCredentials("missing")
+	assert.ErrorContains(err, "does not exist")
+	assert.Equal(1, writeFileCallCount)
+}
+
+func TestGetCredentialsProfiles(t *testing.T) {
+	setupTestCases()
+	readFileReturnValue = []byte("[default]\naws_access_key_id = static\n\n[test-profile]\naws_access_key_id = access-key-id\nx_security_token_expires = 2023-05-01T12:00:00Z\nx_op2aws_managed = true\n\n[other]\naws_access_key_id = static\n")
+
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+	names, err := client.GetCredentialsProfiles()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test-profile"}, names, "Only the profiles, that have been written by op2aws, should be returned")
+
+	readFileReturnValue = nil
+	errorIsNotExistReturnValue = true
+	names, err = client.GetCredentialsProfiles()
+
+	assert.Nil(t, err)
+	assert.Empty(t, names)
+}
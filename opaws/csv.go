@@ -0,0 +1,48 @@
+package opaws
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	CSV_ACCESS_KEY_ID_COLUMN     = "access key id"
+	CSV_SECRET_ACCESS_KEY_COLUMN = "secret access key"
+)
+
+// ParseAccessKeyCsv returns the access key of a CSV file downloaded from the IAM console.
+func ParseAccessKeyCsv(r io.Reader) (string, string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(records) < 2 {
+		return "", "", fmt.Errorf("The CSV file contains no access key")
+	}
+
+	accessKeyIdColumn, secretAccessKeyColumn := -1, -1
+	for i, header := range records[0] {
+		// The IAM console writes a byte order mark at the beginning of the file.
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff"))) {
+		case CSV_ACCESS_KEY_ID_COLUMN:
+			accessKeyIdColumn = i
+		case CSV_SECRET_ACCESS_KEY_COLUMN:
+			secretAccessKeyColumn = i
+		}
+	}
+
+	if accessKeyIdColumn == -1 || secretAccessKeyColumn == -1 {
+		return "", "", fmt.Errorf("The CSV file has no columns \"Access key ID\" and \"Secret access key\"")
+	}
+
+	accessKeyId := strings.TrimSpace(records[1][accessKeyIdColumn])
+	secretAccessKey := strings.TrimSpace(records[1][secretAccessKeyColumn])
+	if accessKeyId == "" || secretAccessKey == "" {
+		return "", "", fmt.Errorf("The CSV file contains no access key")
+	}
+
+	return accessKeyId, secretAccessKey, nil
+}
@@ -0,0 +1,42 @@
+package opaws_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/opaws"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccessKeyCsv(t *testing.T) {
+	for i, v := range []string{
+		"\ufeffAccess key ID,Secret access key\nAKIATEST,secret/test+key\n",
+		"User name,Password,Access key ID,Secret access key,Console login link\ntest-user,,AKIATEST,secret/test+key,https://test.signin.aws.amazon.com/console\n",
+		"Access key ID,Secret access key\r\n AKIATEST , secret/test+key\r\n",
+	} {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			accessKeyId, secretAccessKey, err := opaws.ParseAccessKeyCsv(strings.NewReader(v))
+
+			assert.Nil(t, err)
+			assert.Equal(t, "AKIATEST", accessKeyId)
+			assert.Equal(t, "secret/test+key", secretAccessKey)
+		})
+	}
+}
+
+func TestParseAccessKeyCsvErrors(t *testing.T) {
+	for i, v := range []string{
+		"",
+		"Access key ID,Secret access key\n",
+		"User name,Password\ntest-user,test-password\n",
+		"Access key ID,Secret access key\nAKIATEST,\n",
+		"Access key ID,Secret access key\n\"AKIATEST,secret\n",
+	} {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			_, _, err := opaws.ParseAccessKeyCsv(strings.NewReader(v))
+
+			assert.NotNil(t, err)
+		})
+	}
+}
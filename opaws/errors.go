@@ -0,0 +1,81 @@
+package opaws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	STAGE_VAULT      = "1password"
+	STAGE_MFA        = "mfa"
+	STAGE_STS        = "sts"
+	STAGE_ROLE_TRUST = "role trust"
+	STAGE_KEY_AGE    = "key age"
+)
+
+var (
+	ErrMFARejected  = errors.New("The MFA code has been rejected")
+	ErrAccessDenied = errors.New("The access has been denied by AWS")
+	ErrRoleTrust    = fmt.Errorf("%w, the trust policy of the role doesn't allow the credentials to assume it", ErrAccessDenied)
+	ErrNetwork      = errors.New("AWS is not reachable")
+	ErrConfigWrite  = errors.New("The config file could not be written")
+)
+
+// StageError tells at which stage generating the credentials failed. Kind is one of the sentinel errors.
+type StageError struct {
+	Stage string
+	Kind  error
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StageError) Is(target error) bool {
+	return e.Kind != nil && errors.Is(e.Kind, target)
+}
+
+func newStageError(stage string, err error) error {
+	return &StageError{Stage: stage, Err: err}
+}
+
+// GetStage returns the stage of the error or an empty string, when the error has no stage.
+func GetStage(err error) string {
+	var stageError *StageError
+	if errors.As(err, &stageError) {
+		return stageError.Stage
+	}
+
+	return ""
+}
+
+func newStsError(err error, assumeRole bool) error {
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		if strings.Contains(awsError.Message(), "MultiFactorAuthentication") {
+			return &StageError{Stage: STAGE_MFA, Kind: ErrMFARejected, Err: err}
+		}
+
+		if awsError.Code() == request.ErrCodeRequestError {
+			return &StageError{Stage: STAGE_STS, Kind: ErrNetwork, Err: err}
+		}
+
+		if awsError.Code() == "AccessDenied" {
+			if assumeRole {
+				return &StageError{Stage: STAGE_ROLE_TRUST, Kind: ErrRoleTrust, Err: err}
+			}
+			return &StageError{Stage: STAGE_STS, Kind: ErrAccessDenied, Err: err}
+		}
+	}
+
+	return newStageError(STAGE_STS, err)
+}
@@ -0,0 +1,326 @@
+package opaws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"nextunit/op2aws/config"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const IMPORT_FROM_AWS_VAULT = "aws-vault"
+
+var (
+	IMPORT_SOURCES = []string{IMPORT_FROM_AWS_VAULT}
+
+	// Profiles with these keys get their credentials from somewhere else than aws-vault.
+	IMPORT_FOREIGN_KEYS = []string{"credential_process", "sso_start_url", "sso_session", "web_identity_token_file", "credential_source"}
+)
+
+// ImportItem is the 1password item with the credentials of a source profile of aws-vault.
+type ImportItem struct {
+	Vault                string `yaml:"vault"`
+	Item                 string `yaml:"item"`
+	LabelAccessKey       string `yaml:"label_accesskey,omitempty"`
+	LabelSecretAccessKey string `yaml:"label_secret_accesskey,omitempty"`
+}
+
+// ProfileImport is the change of a section of the config file. Old is empty for a new section.
+type ProfileImport struct {
+	Name string
+	Old  []string
+	New  []string
+}
+
+type importProfile struct {
+	name    string
+	section *iniSection
+}
+
+// ParseImportMapping decodes the mapping file, that maps the names of the source profiles onto 1password items:
+//
+//	company:
+//	  vault: Employee
+//	  item: AWS company
+func ParseImportMapping(content []byte) (map[string]ImportItem, error) {
+	items := map[string]ImportItem{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&items); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	for name, item := range items {
+		if item.Vault == "" || item.Item == "" {
+			return nil, fmt.Errorf("The source profile %s needs a vault and an item", name)
+		}
+	}
+
+	return items, nil
+}
+
+func isForeignProfile(section *iniSection) bool {
+	for _, key := range IMPORT_FOREIGN_KEYS {
+		if _, ok := section.get(key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getImportProfiles(file *iniFile) map[string]importProfile {
+	profiles := map[string]importProfile{}
+	for _, section := range file.sections {
+		name, ok := getProfileName(section.name)
+		if ok && file.section(section.name) == section {
+			profiles[name] = importProfile{name: name, section: section}
+		}
+	}
+
+	return profiles
+}
+
+// isSourceProfile returns whether the profile has its credentials inside of aws-vault.
+func isSourceProfile(profiles map[string]importProfile, name string) bool {
+	profile, ok := profiles[name]
+	if !ok {
+		return true
+	}
+
+	_, hasRole := profile.section.get("role_arn")
+	return !hasRole && !isForeignProfile(profile.section)
+}
+
+// getSourceProfiles returns the source profiles, that have their credentials inside of aws-vault.
+func getSourceProfiles(file *iniFile) []string {
+	profiles := getImportProfiles(file)
+
+	names := map[string]bool{}
+	for name, profile := range profiles {
+		source, ok := profile.section.get("source_profile")
+		if !ok || isForeignProfile(profile.section) {
+			continue
+		}
+
+		if source == name || isSourceProfile(profiles, source) {
+			names[source] = true
+		}
+	}
+
+	return config.GetSortedKeys(names)
+}
+
+// GetAwsVaultSourceProfiles returns the profiles of aws-vault, that need a 1password item.
+func (c AWSConfig) GetAwsVaultSourceProfiles() ([]string, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return getSourceProfiles(file), nil
+}
+
+func copyLines(lines []string) []string {
+	return append([]string{}, trimEmptyLines(lines)...)
+}
+
+// setCredentialProcess replaces the keys of aws-vault with the keys of op2aws and keeps all other keys.
+func setCredentialProcess(section *iniSection, profile *OpProfile) {
+	for _, key := range []string{"role_arn", "source_profile", "mfa_serial"} {
+		section.remove(key)
+	}
+
+	for _, key := range profile.getKeys() {
+		if key[1] != "" {
+			section.add(key[0], key[1])
+		}
+	}
+}
+
+// isImportedChain returns whether the role of a role leads to a role of a source profile with an item.
+func isImportedChain(profiles map[string]importProfile, name string, items map[string]ImportItem, sourceProfiles []string, chain []string) bool {
+	profile, ok := profiles[name]
+	if !ok || contains(chain, name) || isForeignProfile(profile.section) {
+		return false
+	}
+
+	_, hasRole := profile.section.get("role_arn")
+	source, hasSource := profile.section.get("source_profile")
+	if !hasRole || !hasSource {
+		return false
+	}
+
+	if contains(sourceProfiles, source) {
+		_, ok := items[source]
+		return ok
+	}
+
+	return isImportedChain(profiles, source, items, sourceProfiles, append(chain, name))
+}
+
+func newImportProfile(name string, item ImportItem) *OpProfile {
+	return &OpProfile{
+		Name:                 name,
+		Vault:                item.Vault,
+		Item:                 item.Item,
+		LabelAccessKey:       item.LabelAccessKey,
+		LabelSecretAccessKey: item.LabelSecretAccessKey,
+	}
+}
+
+// importAwsVault changes the profiles of aws-vault, whose source profile has an item. Roles of other roles are chained
+// with op2aws_source_profile.
+func importAwsVault(file *iniFile, items map[string]ImportItem) []ProfileImport {
+	profiles := getImportProfiles(file)
+	sourceProfiles := getSourceProfiles(file)
+	imports := []ProfileImport{}
+
+	// The sections are changed inside of the loop.
+	sourceMFA := map[string]string{}
+	for _, name := range sourceProfiles {
+		if profile, ok := profiles[name]; ok {
+			sourceMFA[name], _ = profile.section.get("mfa_serial")
+		}
+	}
+	chained := map[string]bool{}
+	for name := range profiles {
+		chained[name] = isImportedChain(profiles, name, items, sourceProfiles, nil)
+	}
+
+	for _, section := range file.sections {
+		name, ok := getProfileName(section.name)
+		if !ok || file.section(section.name) != section || isForeignProfile(section) {
+			continue
+		}
+
+		old := copyLines(section.lines)
+		mfa, _ := section.get("mfa_serial")
+		role, hasRole := section.get("role_arn")
+		source, hasSource := section.get("source_profile")
+
+		if !hasRole {
+			item, ok := items[name]
+			if !ok {
+				continue
+			}
+
+			profile := newImportProfile(name, item)
+			profile.MFA = mfa
+			setCredentialProcess(section, profile)
+		} else {
+			item, ok := items[source]
+			if hasSource && !contains(sourceProfiles, source) && chained[name] {
+				setCredentialProcess(section, &OpProfile{Name: name, AssumeRole: role, MFA: mfa, SourceProfile: source})
+				imports = append(imports, ProfileImport{Name: name, Old: old, New: copyLines(section.lines)})
+				continue
+			}
+
+			if !hasSource || !ok || !contains(sourceProfiles, source) {
+				continue
+			}
+
+			// aws-vault uses the mfa_serial of the source profile, when the role has none.
+			if mfa == "" {
+				mfa = sourceMFA[source]
+			}
+
+			profile := newImportProfile(name, item)
+			profile.AssumeRole = role
+			profile.MFA = mfa
+			setCredentialProcess(section, profile)
+		}
+
+		imports = append(imports, ProfileImport{Name: name, Old: old, New: copyLines(section.lines)})
+	}
+
+	// Source profiles, that only exist inside of aws-vault, are added.
+	for _, name := range config.GetSortedKeys(items) {
+		if _, ok := profiles[name]; ok || !contains(sourceProfiles, name) {
+			continue
+		}
+
+		file.setSection(profileSectionName(name), []string{"credential_process = " + newImportProfile(name, items[name]).GetCredentialProcess()})
+		imports = append(imports, ProfileImport{Name: name, New: copyLines(file.section(profileSectionName(name)).lines)})
+	}
+
+	return imports
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetAwsVaultImports returns the changes of ImportAwsVault without writing them.
+func (c AWSConfig) GetAwsVaultImports(items map[string]ImportItem) ([]ProfileImport, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return importAwsVault(file, items), nil
+}
+
+// ImportAwsVault rewrites the profiles of aws-vault into profiles of op2aws with the credentials of the items.
+func (c AWSConfig) ImportAwsVault(items map[string]ImportItem) ([]ProfileImport, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	imports := importAwsVault(file, items)
+	if len(imports) == 0 {
+		return imports, nil
+	}
+
+	return imports, c.write(file, c.getFileMode())
+}
+
+// GetDiff returns the lines of the section with the removed lines marked with - and the added lines with +.
+func (i ProfileImport) GetDiff() string {
+	// common[o][n] is the length of the longest common subsequence of i.Old[o:] and i.New[n:].
+	common := make([][]int, len(i.Old)+1)
+	for o := range common {
+		common[o] = make([]int, len(i.New)+1)
+	}
+	for o := len(i.Old) - 1; o >= 0; o-- {
+		for n := len(i.New) - 1; n >= 0; n-- {
+			if i.Old[o] == i.New[n] {
+				common[o][n] = common[o+1][n+1] + 1
+			} else if common[o+1][n] >= common[o][n+1] {
+				common[o][n] = common[o+1][n]
+			} else {
+				common[o][n] = common[o][n+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	o, n := 0, 0
+	for o < len(i.Old) || n < len(i.New) {
+		switch {
+		case o < len(i.Old) && n < len(i.New) && i.Old[o] == i.New[n]:
+			lines = append(lines, "  "+strings.TrimSpace(i.Old[o]))
+			o++
+			n++
+		case o < len(i.Old) && (n == len(i.New) || common[o+1][n] >= common[o][n+1]):
+			lines = append(lines, "- "+strings.TrimSpace(i.Old[o]))
+			o++
+		default:
+			lines = append(lines, "+ "+strings.TrimSpace(i.New[n]))
+			n++
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
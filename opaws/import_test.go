@@ -0,0 +1,114 @@
+package opaws_test
+
+import (
+	"nextunit/op2aws/opaws"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var AWS_VAULT_CONFIG = "[default]\nregion = eu-central-1\n\n" +
+	"[profile company]\nmfa_serial = arn:aws:iam::111111111111:mfa/jane\nregion = eu-west-1\n\n" +
+	"[profile admin]\nsource_profile = company\nrole_arn = arn:aws:iam::222222222222:role/Admin\n\n" +
+	"[profile chained]\nsource_profile = admin\nrole_arn = arn:aws:iam::333333333333:role/ReadOnly\n\n" +
+	"[profile private-admin]\nsource_profile = private\nrole_arn = arn:aws:iam::444444444444:role/Admin\nmfa_serial = arn:aws:iam::444444444444:mfa/jane\n\n" +
+	"[profile sso]\nsso_session = company\nsso_account_id = 555555555555\n\n" +
+	"[profile unmapped-admin]\nsource_profile = unmapped\nrole_arn = arn:aws:iam::666666666666:role/Admin\n"
+
+func TestParseImportMapping(t *testing.T) {
+	items, err := opaws.ParseImportMapping([]byte("company:\n  vault: Employee\n  item: AWS company\n  label_accesskey: key\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]opaws.ImportItem{
+		"company": {Vault: "Employee", Item: "AWS company", LabelAccessKey: "key"},
+	}, items)
+
+	_, err = opaws.ParseImportMapping([]byte("company:\n  vault: Employee\n"))
+	assert.ErrorContains(t, err, "needs a vault and an item")
+
+	_, err = opaws.ParseImportMapping([]byte("company:\n  vualt: Employee\n  item: AWS company\n"))
+	assert.NotNil(t, err)
+}
+
+func TestGetAwsVaultSourceProfiles(t *testing.T) {
+	setupTestCases()
+	readFileReturnValue = []byte(AWS_VAULT_CONFIG)
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	names, err := client.GetAwsVaultSourceProfiles()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"company", "private", "unmapped"}, names, "Roles are no source profiles of aws-vault")
+}
+
+func TestImportAwsVault(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	setCredentialProcessOS(t, "linux")
+	readFileReturnValue = []byte(AWS_VAULT_CONFIG)
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	items := map[string]opaws.ImportItem{
+		"company": {Vault: "Employee", Item: "AWS company"},
+		"private": {Vault: "Private", Item: "AWS private"},
+	}
+
+	imports, err := client.GetAwsVaultImports(items)
+	assert.Nil(err)
+	assert.Equal(0, writeFileCallCount, "GetAwsVaultImports should not write the file")
+	assert.Len(imports, 5)
+
+	imports, err = client.ImportAwsVault(items)
+	assert.Nil(err)
+	assert.Equal([]string{"company", "admin", "chained", "private-admin", "private"}, []string{imports[0].Name, imports[1].Name, imports[2].Name, imports[3].Name, imports[4].Name})
+	assert.Nil(imports[4].Old, "The source profile private only exists inside of aws-vault")
+
+	assert.Equal("[default]\nregion = eu-central-1\n\n"+
+		"[profile company]\nregion = eu-west-1\ncredential_process = op2aws cli Employee 'AWS company' -m arn:aws:iam::111111111111:mfa/jane --profile-name company\n\n"+
+		"[profile admin]\ncredential_process = op2aws cli Employee 'AWS company' -a arn:aws:iam::222222222222:role/Admin -m arn:aws:iam::111111111111:mfa/jane --profile-name admin\n\n"+
+		"[profile chained]\ncredential_process = op2aws cli --profile chained\nop2aws_source_profile = admin\nop2aws_role_arn = arn:aws:iam::333333333333:role/ReadOnly\n\n"+
+		"[profile private-admin]\ncredential_process = op2aws cli Private 'AWS private' -a arn:aws:iam::444444444444:role/Admin -m arn:aws:iam::444444444444:mfa/jane --profile-name private-admin\n\n"+
+		"[profile sso]\nsso_session = company\nsso_account_id = 555555555555\n\n"+
+		"[profile unmapped-admin]\nsource_profile = unmapped\nrole_arn = arn:aws:iam::666666666666:role/Admin\n\n"+
+		"[profile private]\ncredential_process = op2aws cli Private 'AWS private' --profile-name private\n", string(writeFileInput[0].data))
+
+	assert.Equal("  [profile admin]\n- source_profile = company\n- role_arn = arn:aws:iam::222222222222:role/Admin\n"+
+		"+ credential_process = op2aws cli Employee 'AWS company' -a arn:aws:iam::222222222222:role/Admin -m arn:aws:iam::111111111111:mfa/jane --profile-name admin", imports[1].GetDiff())
+}
+
+func TestImportAwsVaultChained(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	setCredentialProcessOS(t, "linux")
+	readFileReturnValue = []byte("[profile company]\nmfa_serial = arn:aws:iam::111111111111:mfa/jane\n\n" +
+		"[profile readonly]\nsource_profile = admin\nrole_arn = arn:aws:iam::333333333333:role/ReadOnly\nmfa_serial = arn:aws:iam::333333333333:mfa/jane\nregion = eu-west-1\n\n" +
+		"[profile admin]\nsource_profile = company\nrole_arn = arn:aws:iam::222222222222:role/Admin\n\n" +
+		"[profile unmapped-admin]\nsource_profile = unmapped\nrole_arn = arn:aws:iam::444444444444:role/Admin\n\n" +
+		"[profile unmapped-readonly]\nsource_profile = unmapped-admin\nrole_arn = arn:aws:iam::555555555555:role/ReadOnly\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	imports, err := client.ImportAwsVault(map[string]opaws.ImportItem{"company": {Vault: "Employee", Item: "AWS company"}})
+	assert.Nil(err)
+	assert.Equal([]string{"company", "readonly", "admin"}, []string{imports[0].Name, imports[1].Name, imports[2].Name},
+		"A chain should be imported before its source profile is rewritten and not at all without an item")
+
+	assert.Equal("[profile company]\ncredential_process = op2aws cli Employee 'AWS company' -m arn:aws:iam::111111111111:mfa/jane --profile-name company\n\n"+
+		"[profile readonly]\nregion = eu-west-1\ncredential_process = op2aws cli --profile readonly\n"+
+		"op2aws_source_profile = admin\nop2aws_role_arn = arn:aws:iam::333333333333:role/ReadOnly\nop2aws_mfa_serial = arn:aws:iam::333333333333:mfa/jane\n\n"+
+		"[profile admin]\ncredential_process = op2aws cli Employee 'AWS company' -a arn:aws:iam::222222222222:role/Admin -m arn:aws:iam::111111111111:mfa/jane --profile-name admin\n\n"+
+		"[profile unmapped-admin]\nsource_profile = unmapped\nrole_arn = arn:aws:iam::444444444444:role/Admin\n\n"+
+		"[profile unmapped-readonly]\nsource_profile = unmapped-admin\nrole_arn = arn:aws:iam::555555555555:role/ReadOnly\n", string(writeFileInput[0].data))
+}
+
+func TestProfileImportGetDiff(t *testing.T) {
+	profileImport := opaws.ProfileImport{
+		Name: "admin",
+		Old:  []string{"[profile admin]", "region = eu-west-1", "source_profile = company", "output = json", "region = eu-west-1"},
+		New:  []string{"[profile admin]", "region = eu-west-1", "credential_process = op2aws cli", "output = json"},
+	}
+
+	assert.Equal(t, "  [profile admin]\n  region = eu-west-1\n- source_profile = company\n+ credential_process = op2aws cli\n  output = json\n- region = eu-west-1", profileImport.GetDiff(),
+		"The diff should keep the order of the lines and show a removed duplicate")
+
+	profileImport.Old = nil
+	assert.Equal(t, "+ [profile admin]\n+ region = eu-west-1\n+ credential_process = op2aws cli\n+ output = json", profileImport.GetDiff())
+}
@@ -0,0 +1,178 @@
+package opaws
+
+import (
+	"strings"
+)
+
+type iniSection struct {
+	name  string
+	lines []string
+}
+
+type iniFile struct {
+	sections []*iniSection
+}
+
+func parseSectionHeader(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+		return "", false
+	}
+
+	return strings.TrimSpace(trimmed[1 : len(trimmed)-1]), true
+}
+
+func parseKeyValue(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+		return "", "", false
+	}
+
+	key, value, found := strings.Cut(trimmed, "=")
+	if !found {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// parseIni keeps every line of the file, so untouched sections are written back as they are.
+func parseIni(content string) *iniFile {
+	file := &iniFile{sections: []*iniSection{{}}}
+	current := file.sections[0]
+
+	for _, line := range strings.Split(content, "\n") {
+		if name, ok := parseSectionHeader(line); ok {
+			current = &iniSection{name: name}
+			file.sections = append(file.sections, current)
+		}
+
+		current.lines = append(current.lines, line)
+	}
+
+	return file
+}
+
+func (s *iniSection) get(key string) (string, bool) {
+	for _, line := range s.lines {
+		k, v, ok := parseKeyValue(line)
+		if ok && k == key {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// set replaces the value of the key. It returns false, when the key does not exist.
+func (s *iniSection) set(key, value string) bool {
+	for i, line := range s.lines {
+		k, _, ok := parseKeyValue(line)
+		if ok && k == key {
+			indentation := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			s.lines[i] = indentation + key + " = " + value
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove deletes every line of the key.
+func (s *iniSection) remove(key string) {
+	lines := []string{}
+	for _, line := range s.lines {
+		if k, _, ok := parseKeyValue(line); ok && k == key {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	s.lines = lines
+}
+
+// add sets the key or adds it behind the last key of the section.
+func (s *iniSection) add(key, value string) {
+	if s.set(key, value) {
+		return
+	}
+
+	last := len(trimEmptyLines(s.lines))
+	indentation := ""
+	for _, line := range s.lines[1:last] {
+		if _, _, ok := parseKeyValue(line); ok {
+			indentation = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			break
+		}
+	}
+
+	lines := append([]string{}, s.lines[:last]...)
+	lines = append(lines, indentation+key+" = "+value)
+	s.lines = append(lines, s.lines[last:]...)
+}
+
+func (f *iniFile) section(name string) *iniSection {
+	for _, s := range f.sections {
+		if s.name == name && len(s.lines) > 0 {
+			if _, ok := parseSectionHeader(s.lines[0]); ok {
+				return s
+			}
+		}
+	}
+
+	return nil
+}
+
+func trimEmptyLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// setSection replaces the lines of an existing section or appends a new section at the end of the file.
+func (f *iniFile) setSection(name string, lines []string) {
+	sectionLines := append([]string{"[" + name + "]"}, lines...)
+
+	if s := f.section(name); s != nil {
+		trailing := len(s.lines) - len(trimEmptyLines(s.lines))
+		s.lines = append(sectionLines, make([]string, trailing)...)
+		return
+	}
+
+	last := f.sections[len(f.sections)-1]
+	last.lines = trimEmptyLines(last.lines)
+	if len(f.sections) > 1 || len(last.lines) > 0 {
+		last.lines = append(last.lines, "")
+	}
+
+	f.sections = append(f.sections, &iniSection{name: name, lines: sectionLines})
+}
+
+func (f *iniFile) removeSection(name string) bool {
+	section := f.section(name)
+
+	for i, s := range f.sections {
+		if section != nil && s == section {
+			f.sections = append(f.sections[:i], f.sections[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *iniFile) String() string {
+	lines := []string{}
+	for _, s := range f.sections {
+		lines = append(lines, s.lines...)
+	}
+
+	content := strings.Join(trimEmptyLines(lines), "\n")
+	if len(content) > 0 {
+		content += "\n"
+	}
+
+	return content
+}
@@ -0,0 +1,131 @@
+package opaws
+
+import (
+	"fmt"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/redact"
+	"nextunit/op2aws/totp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// MFA_AUTO detects the MFA device of the IAM user, when the credentials are generated.
+const MFA_AUTO = "auto"
+
+// The clock of EnrollMFA, so the tests don't wait for the next period.
+var (
+	MFA_NOW   = time.Now
+	MFA_SLEEP = time.Sleep
+)
+
+// untilNextPeriod returns the time until the next TOTP period starts.
+func untilNextPeriod(now time.Time) time.Duration {
+	return totp.PERIOD - time.Duration(now.UnixNano()%int64(totp.PERIOD))
+}
+
+// EnrollMFA creates and enables a virtual MFA device, whose secret is stored inside of the vault, and returns its serial
+// number. Without a device name the name of the IAM user is used.
+func (client OpAWS) EnrollMFA(deviceName string) (string, error) {
+	accessKeyId, secretAccessKey, err := client.getVaultAccessKey()
+	if err != nil {
+		return "", err
+	}
+
+	iamClient := client.generateIamClient(accessKeyId, secretAccessKey)
+	user, err := iamClient.GetUser(&iam.GetUserInput{})
+	if err != nil {
+		return "", fmt.Errorf("Unable to get the IAM user: %w", err)
+	}
+
+	if deviceName == "" {
+		deviceName = *user.User.UserName
+	}
+
+	output, err := iamClient.CreateVirtualMFADevice(&iam.CreateVirtualMFADeviceInput{VirtualMFADeviceName: &deviceName})
+	if err != nil {
+		return "", fmt.Errorf("Unable to create the virtual MFA device: %w", err)
+	}
+	serialNumber := output.VirtualMFADevice.SerialNumber
+	secret := string(output.VirtualMFADevice.Base32StringSeed)
+	redact.Add(secret)
+
+	steps := rollback{}
+	steps.add(func() error {
+		_, err := iamClient.DeleteVirtualMFADevice(&iam.DeleteVirtualMFADeviceInput{SerialNumber: serialNumber})
+		return err
+	})
+
+	if err := client.opClient.SetOTP(secret); err != nil {
+		return "", steps.run(newStageError(STAGE_VAULT, err))
+	}
+	steps.add(client.opClient.RemoveOTP)
+
+	// AWS expects two consecutive codes, the first one is of the previous period.
+	now := MFA_NOW()
+	code1, err := totp.Generate(secret, now.Add(-totp.PERIOD))
+	if err != nil {
+		return "", steps.run(err)
+	}
+	code2, err := totp.Generate(secret, now)
+	if err != nil {
+		return "", steps.run(err)
+	}
+	redact.AddTemporary(redact.OTP_TTL, code1, code2)
+
+	_, err = iamClient.EnableMFADevice(&iam.EnableMFADeviceInput{
+		UserName:            user.User.UserName,
+		SerialNumber:        serialNumber,
+		AuthenticationCode1: aws.String(code1),
+		AuthenticationCode2: aws.String(code2),
+	})
+	if err != nil {
+		return "", steps.run(fmt.Errorf("Unable to enable the virtual MFA device: %w", err))
+	}
+
+	// AWS rejects a code, that has already been used.
+	wait := untilNextPeriod(MFA_NOW())
+	logger.Info("Waiting for the next MFA code", "wait", wait.Round(time.Second).String())
+	MFA_SLEEP(wait)
+
+	return *serialNumber, nil
+}
+
+// GetMFADevices returns the serial numbers of the MFA devices of the IAM user inside of the vault.
+func (client OpAWS) GetMFADevices() ([]string, error) {
+	accessKeyId, secretAccessKey, err := client.getVaultAccessKey()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.generateIamClient(accessKeyId, secretAccessKey).ListMFADevices(&iam.ListMFADevicesInput{})
+	if err != nil {
+		return nil, newStageError(STAGE_MFA, fmt.Errorf("Unable to list the MFA devices: %w", err))
+	}
+
+	serialNumbers := []string{}
+	for _, device := range output.MFADevices {
+		serialNumbers = append(serialNumbers, aws.StringValue(device.SerialNumber))
+	}
+
+	return serialNumbers, nil
+}
+
+// detectMFA returns the serial number of the only MFA device of the IAM user inside of the vault.
+func (client OpAWS) detectMFA() (string, error) {
+	serialNumbers, err := client.GetMFADevices()
+	if err != nil {
+		return "", err
+	}
+
+	switch len(serialNumbers) {
+	case 0:
+		return "", newStageError(STAGE_MFA, fmt.Errorf("The IAM user has no MFA device"))
+	case 1:
+		return serialNumbers[0], nil
+	default:
+		return "", newStageError(STAGE_MFA, fmt.Errorf("The IAM user has %d MFA devices, set one of them instead of %s: %s", len(serialNumbers), MFA_AUTO, strings.Join(serialNumbers, ", ")))
+	}
+}
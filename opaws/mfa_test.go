@@ -0,0 +1,247 @@
+package opaws_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/totp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	setOtpReturnValue                 error
+	createVirtualMFADeviceReturnValue *iam.CreateVirtualMFADeviceOutput
+	enableMFADeviceReturnValue        error
+
+	removeOtpCallCount              int
+	deleteVirtualMFADeviceCallCount int
+
+	setOtpInput                 string
+	createVirtualMFADeviceInput *iam.CreateVirtualMFADeviceInput
+	enableMFADeviceInput        *iam.EnableMFADeviceInput
+)
+
+func setupMFATestCase() {
+	setupRotateTestCase()
+	opaws.MFA_NOW = time.Now
+	opaws.MFA_SLEEP = func(d time.Duration) {}
+
+	setOtpReturnValue = nil
+	createVirtualMFADeviceReturnValue = &iam.CreateVirtualMFADeviceOutput{
+		VirtualMFADevice: &iam.VirtualMFADevice{
+			SerialNumber:     aws.String("arn:aws:iam::000000000000:mfa/test-user"),
+			Base32StringSeed: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"),
+		},
+	}
+	enableMFADeviceReturnValue = nil
+
+	removeOtpCallCount = 0
+	deleteVirtualMFADeviceCallCount = 0
+
+	setOtpInput = ""
+	createVirtualMFADeviceInput = nil
+	enableMFADeviceInput = nil
+}
+
+func (awsVaultTest) SetOTP(otp string) error {
+	setOtpInput = otp
+	return setOtpReturnValue
+}
+
+func (awsVaultTest) RemoveOTP() error {
+	removeOtpCallCount++
+	return nil
+}
+
+func (iamApiTest) GetUser(input *iam.GetUserInput) (*iam.GetUserOutput, error) {
+	return &iam.GetUserOutput{User: &iam.User{UserName: aws.String("test-user")}}, nil
+}
+
+func (iamApiTest) CreateVirtualMFADevice(input *iam.CreateVirtualMFADeviceInput) (*iam.CreateVirtualMFADeviceOutput, error) {
+	createVirtualMFADeviceInput = input
+	if createVirtualMFADeviceReturnValue == nil {
+		return nil, fmt.Errorf("Test error CreateVirtualMFADevice")
+	}
+	return createVirtualMFADeviceReturnValue, nil
+}
+
+func (iamApiTest) EnableMFADevice(input *iam.EnableMFADeviceInput) (*iam.EnableMFADeviceOutput, error) {
+	enableMFADeviceInput = input
+	if enableMFADeviceReturnValue != nil {
+		return nil, enableMFADeviceReturnValue
+	}
+	return &iam.EnableMFADeviceOutput{}, nil
+}
+
+func (iamApiTest) DeleteVirtualMFADevice(input *iam.DeleteVirtualMFADeviceInput) (*iam.DeleteVirtualMFADeviceOutput, error) {
+	deleteVirtualMFADeviceCallCount++
+	return &iam.DeleteVirtualMFADeviceOutput{}, nil
+}
+
+func TestEnrollMFA(t *testing.T) {
+	setupMFATestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	serialNumber, err := client.EnrollMFA("")
+
+	assert.Nil(err)
+	assert.Equal("arn:aws:iam::000000000000:mfa/test-user", serialNumber)
+	assert.Equal("test-user", *createVirtualMFADeviceInput.VirtualMFADeviceName, "The name of the user should be the default name of the device")
+	assert.Equal("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", setOtpInput)
+	assert.Equal("test-user", *enableMFADeviceInput.UserName)
+	assert.Equal(serialNumber, *enableMFADeviceInput.SerialNumber)
+
+	// The codes are generated during the test, so the period might have changed in between. Both codes have to be
+	// of periods, that already started.
+	now := time.Now()
+	getCodes := func(offsets ...time.Duration) []string {
+		codes := []string{}
+		for _, offset := range offsets {
+			code, _ := totp.Generate("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", now.Add(offset))
+			codes = append(codes, code)
+		}
+		return codes
+	}
+	assert.Contains(getCodes(-2*totp.PERIOD, -totp.PERIOD), *enableMFADeviceInput.AuthenticationCode1)
+	assert.Contains(getCodes(-totp.PERIOD, 0), *enableMFADeviceInput.AuthenticationCode2)
+	assert.NotEqual(*enableMFADeviceInput.AuthenticationCode1, *enableMFADeviceInput.AuthenticationCode2, "The codes should be consecutive")
+
+	_, err = client.EnrollMFA("test-device")
+	assert.Nil(err)
+	assert.Equal("test-device", *createVirtualMFADeviceInput.VirtualMFADeviceName)
+}
+
+func TestEnrollMFAWaitsForNextPeriod(t *testing.T) {
+	setupMFATestCase()
+	assert := assert.New(t)
+
+	now := time.Date(2026, 10, 19, 12, 0, 20, 0, time.UTC)
+	slept := time.Duration(0)
+	opaws.MFA_NOW = func() time.Time { return now.Add(slept) }
+	opaws.MFA_SLEEP = func(d time.Duration) { slept += d }
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	_, err := client.EnrollMFA("")
+	assert.Nil(err)
+
+	code2, _ := totp.Generate("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", now)
+	assert.Equal(code2, *enableMFADeviceInput.AuthenticationCode2)
+	assert.Equal(10*time.Second, slept, "The device should only be used with the code of the next period")
+
+	next, _ := totp.Generate("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", now.Add(slept))
+	assert.NotEqual(code2, next)
+}
+
+func TestEnrollMFAErrors(t *testing.T) {
+	testCasesMFA := []struct {
+		name                                    string
+		setup                                   func()
+		expectedError                           string
+		expectedRemoveOtpCallCount              int
+		expectedDeleteVirtualMFADeviceCallCount int
+	}{
+		{
+			name:          "create device",
+			setup:         func() { createVirtualMFADeviceReturnValue = nil },
+			expectedError: "Test error CreateVirtualMFADevice",
+		},
+		{
+			name:                                    "store secret",
+			setup:                                   func() { setOtpReturnValue = fmt.Errorf("Test error SetOTP") },
+			expectedError:                           "Test error SetOTP",
+			expectedDeleteVirtualMFADeviceCallCount: 1,
+		},
+		{
+			name: "invalid secret",
+			setup: func() {
+				createVirtualMFADeviceReturnValue.VirtualMFADevice.Base32StringSeed = []byte("not-base32!")
+			},
+			expectedError:                           "Base32",
+			expectedRemoveOtpCallCount:              1,
+			expectedDeleteVirtualMFADeviceCallCount: 1,
+		},
+		{
+			name:                                    "enable device",
+			setup:                                   func() { enableMFADeviceReturnValue = fmt.Errorf("Test error EnableMFADevice") },
+			expectedError:                           "Test error EnableMFADevice",
+			expectedRemoveOtpCallCount:              1,
+			expectedDeleteVirtualMFADeviceCallCount: 1,
+		},
+	}
+
+	for _, v := range testCasesMFA {
+		t.Run(v.name, func(t *testing.T) {
+			setupMFATestCase()
+			v.setup()
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			_, err := client.EnrollMFA("")
+
+			assert.ErrorContains(t, err, v.expectedError)
+			assert.Equal(t, v.expectedRemoveOtpCallCount, removeOtpCallCount)
+			assert.Equal(t, v.expectedDeleteVirtualMFADeviceCallCount, deleteVirtualMFADeviceCallCount)
+		})
+	}
+}
+
+func TestGetMFADevices(t *testing.T) {
+	setupAuditTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	serialNumbers, err := client.GetMFADevices()
+
+	assert.Nil(err)
+	assert.Equal([]string{"test-mfa"}, serialNumbers)
+	assert.Nil(listMFADevicesInput.UserName, "The devices of the user of the access key should be listed")
+
+	listMFADevicesReturnValue = nil
+	_, err = client.GetMFADevices()
+	assert.ErrorContains(err, "Test error ListMFADevices")
+	assert.Equal(opaws.STAGE_MFA, opaws.GetStage(err))
+}
+
+func TestUsingAutoMFA(t *testing.T) {
+	setupAuditTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	client.UseMFA(opaws.MFA_AUTO)
+	_, err := client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal("test-mfa", *getSessionTokenInput.SerialNumber)
+	assert.Equal(opaws.MFA_AUTO, client.GetMFA(), "The detected device should not change the client")
+	assert.Equal("test-mfa", client.GetUsedMFA())
+
+	client.AssumeRole("test-assume-role")
+	_, err = client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal("test-mfa", *assumeRoleInput.SerialNumber)
+}
+
+func TestUsingAutoMFAErrors(t *testing.T) {
+	for name, devices := range map[string][]*iam.MFADevice{
+		"no device":       {},
+		"several devices": {{SerialNumber: aws.String("test-mfa-1")}, {SerialNumber: aws.String("test-mfa-2")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupAuditTestCase()
+			listMFADevicesReturnValue = &iam.ListMFADevicesOutput{MFADevices: devices}
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			client.UseMFA(opaws.MFA_AUTO)
+			_, err := client.GetCredentials()
+
+			assert.NotNil(t, err)
+			assert.Equal(t, opaws.STAGE_MFA, opaws.GetStage(err))
+			assert.Equal(t, 0, getSessionTokenCallCount)
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package opaws
 
 import (
+	"fmt"
 	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/logger"
+	"nextunit/op2aws/redact"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -16,23 +20,82 @@ type OpAWS struct {
 	awsClient OpAWSInput
 
 	mfa         string
+	usedMFA     string
 	assume_role string
+
+	sessionName string
+	region      string
+	duration    time.Duration
+
+	sourceCredentials *sts.Credentials
 }
 
-func (client OpAWS) generateStsClient() (stsiface.STSAPI, error) {
+// addCredentials redacts the secrets of the credentials.
+func addCredentials(c *sts.Credentials) {
+	if c != nil {
+		redact.Add(aws.StringValue(c.SecretAccessKey), aws.StringValue(c.SessionToken))
+	}
+}
+
+func (client OpAWS) getVaultAccessKey() (string, string, error) {
 	accessKeyId, err := client.opClient.GetAccessKeyId()
 	if err != nil {
-		return nil, err
+		return "", "", newStageError(STAGE_VAULT, err)
 	}
 
 	secretAccessKey, err := client.opClient.GetSecretAccessKey()
+	if err != nil {
+		return "", "", newStageError(STAGE_VAULT, err)
+	}
+	redact.Add(secretAccessKey)
+
+	return accessKeyId, secretAccessKey, nil
+}
+
+func (client OpAWS) getOTP() (string, error) {
+	otp, err := client.opClient.GetOTP()
+	if err != nil {
+		return "", newStageError(STAGE_MFA, err)
+	}
+	redact.AddTemporary(redact.OTP_TTL, otp)
+
+	return otp, nil
+}
+
+// newStsClient returns a STS client for the credentials inside of the region of the profile, if it is set.
+func (client OpAWS) newStsClient(c *credentials.Credentials) stsiface.STSAPI {
+	awsConfig := &aws.Config{Credentials: c}
+	if client.region != "" {
+		awsConfig.Region = aws.String(client.region)
+	}
+
+	return client.awsClient.NewSts(client.awsClient.NewSession(awsConfig))
+}
+
+// getDurationSeconds returns the duration of the credentials or nil for the default duration of STS.
+func (client OpAWS) getDurationSeconds() *int64 {
+	if client.duration == 0 {
+		return nil
+	}
+
+	return aws.Int64(int64(client.duration.Seconds()))
+}
+
+func (client OpAWS) generateStsClient() (stsiface.STSAPI, error) {
+	if client.sourceCredentials != nil {
+		return client.newStsClient(credentials.NewStaticCredentials(
+			*client.sourceCredentials.AccessKeyId,
+			*client.sourceCredentials.SecretAccessKey,
+			*client.sourceCredentials.SessionToken,
+		)), nil
+	}
+
+	accessKeyId, secretAccessKey, err := client.getVaultAccessKey()
 	if err != nil {
 		return nil, err
 	}
 
-	return client.awsClient.NewSts(client.awsClient.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(accessKeyId, secretAccessKey, ""),
-	})), nil
+	return client.newStsClient(credentials.NewStaticCredentials(accessKeyId, secretAccessKey, "")), nil
 }
 
 func (client OpAWS) generateSessionToken() (*sts.Credentials, error) {
@@ -41,10 +104,10 @@ func (client OpAWS) generateSessionToken() (*sts.Credentials, error) {
 		return nil, err
 	}
 
-	input := &sts.GetSessionTokenInput{}
+	input := &sts.GetSessionTokenInput{DurationSeconds: client.getDurationSeconds()}
 
 	if len(client.mfa) != 0 {
-		otp, err := client.opClient.GetOTP()
+		otp, err := client.getOTP()
 		if err != nil {
 			return nil, err
 		}
@@ -53,10 +116,12 @@ func (client OpAWS) generateSessionToken() (*sts.Credentials, error) {
 		input.TokenCode = &otp
 	}
 
+	logger.Info("Requesting credentials", "api", "sts:GetSessionToken", "mfa", client.mfa)
 	output, err := stsClient.GetSessionToken(input)
 	if err != nil {
-		return nil, err
+		return nil, newStsError(err, false)
 	}
+	addCredentials(output.Credentials)
 
 	return output.Credentials, nil
 }
@@ -67,10 +132,14 @@ func (client OpAWS) generateAssumedRoleCredentials() (*sts.Credentials, error) {
 		return nil, err
 	}
 
-	input := &sts.AssumeRoleInput{RoleArn: &client.assume_role, RoleSessionName: &DEFAULT_SESSION_NAME}
+	sessionName := client.sessionName
+	if sessionName == "" {
+		sessionName = DEFAULT_SESSION_NAME
+	}
+	input := &sts.AssumeRoleInput{RoleArn: &client.assume_role, RoleSessionName: &sessionName, DurationSeconds: client.getDurationSeconds()}
 
 	if len(client.mfa) != 0 {
-		otp, err := client.opClient.GetOTP()
+		otp, err := client.getOTP()
 		if err != nil {
 			return nil, err
 		}
@@ -79,16 +148,41 @@ func (client OpAWS) generateAssumedRoleCredentials() (*sts.Credentials, error) {
 		input.TokenCode = &otp
 	}
 
+	logger.Info("Requesting credentials", "api", "sts:AssumeRole", "role", client.assume_role, "mfa", aws.StringValue(input.SerialNumber), "source_credentials", client.sourceCredentials != nil)
 	output, err := stsClient.AssumeRole(input)
 	if err != nil {
-		return nil, err
+		return nil, newStsError(err, true)
 	}
+	addCredentials(output.Credentials)
 
 	return output.Credentials, nil
 }
 
+// GetCredentials generates the credentials. GetUsedMFA returns the MFA device of them afterwards.
 // TODO: Missing - static credentials
-func (client OpAWS) GetCredentials() (*sts.Credentials, error) {
+func (client *OpAWS) GetCredentials() (*sts.Credentials, error) {
+	// The client keeps MFA_AUTO, the detected device is only used inside of a copy.
+	resolved := *client
+	credentials, err := resolved.getCredentials()
+	client.usedMFA = resolved.mfa
+
+	return credentials, redact.Error(err)
+}
+
+func (client *OpAWS) getCredentials() (*sts.Credentials, error) {
+	if client.sourceCredentials != nil && len(client.assume_role) == 0 {
+		return nil, fmt.Errorf("Source credentials can only be used to assume a role")
+	}
+
+	if client.mfa == MFA_AUTO {
+		mfa, err := client.detectMFA()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Detected the MFA device", "mfa", mfa)
+		client.mfa = mfa
+	}
+
 	if len(client.assume_role) == 0 {
 		return client.generateSessionToken()
 	}
@@ -96,10 +190,28 @@ func (client OpAWS) GetCredentials() (*sts.Credentials, error) {
 	return client.generateAssumedRoleCredentials()
 }
 
+// GetCallerIdentity returns the identity of the credentials.
+func (client OpAWS) GetCallerIdentity(c *sts.Credentials) (*sts.GetCallerIdentityOutput, error) {
+	addCredentials(c)
+	stsClient := client.newStsClient(credentials.NewStaticCredentials(*c.AccessKeyId, *c.SecretAccessKey, aws.StringValue(c.SessionToken)))
+
+	output, err := stsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, redact.Error(newStsError(err, false))
+	}
+
+	return output, nil
+}
+
 func (client OpAWS) GetMFA() string {
 	return client.mfa
 }
 
+// GetUsedMFA returns the MFA device of the last generated credentials.
+func (client OpAWS) GetUsedMFA() string {
+	return client.usedMFA
+}
+
 func (client OpAWS) GetAssumeRole() string {
 	return client.assume_role
 }
@@ -112,6 +224,27 @@ func (client *OpAWS) AssumeRole(assume_role string) {
 	client.assume_role = assume_role
 }
 
+// UseSessionName sets the session name of the assumed role instead of DEFAULT_SESSION_NAME.
+func (client *OpAWS) UseSessionName(sessionName string) {
+	client.sessionName = sessionName
+}
+
+// UseRegion sets the region of the STS endpoint.
+func (client *OpAWS) UseRegion(region string) {
+	client.region = region
+}
+
+// UseDuration sets the duration of the credentials. With 0 STS uses its default duration.
+func (client *OpAWS) UseDuration(duration time.Duration) {
+	client.duration = duration
+}
+
+// UseSourceCredentials assumes the role with the credentials instead of the ones inside of the vault.
+func (client *OpAWS) UseSourceCredentials(sourceCredentials *sts.Credentials) {
+	addCredentials(sourceCredentials)
+	client.sourceCredentials = sourceCredentials
+}
+
 func New(opClient awsvault.Vault, awsClient OpAWSInput) *OpAWS {
 	return &OpAWS{opClient: opClient, awsClient: awsClient}
 }
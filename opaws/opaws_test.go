@@ -1,12 +1,19 @@
 package opaws_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/logger"
 	"nextunit/op2aws/opaws"
+	"nextunit/op2aws/redact"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -20,15 +27,20 @@ var (
 	getOtpReturnValue             *string
 	assumeRoleReturnValue         *sts.AssumeRoleOutput
 	getSessionTokenReturnValue    *sts.GetSessionTokenOutput
+	getCallerIdentityReturnValue  *sts.GetCallerIdentityOutput
+	stsErrorReturnValue           error
+	testErrorMessage              string
 
 	getAccessKeyIdCallCount     int
 	getSecretAccessKeyCallCount int
 	getOtpCallCount             int
 	assumeRoleCallCount         int
 	getSessionTokenCallCount    int
+	getCallerIdentityCallCount  int
 
 	assumeRoleInput      *sts.AssumeRoleInput
 	getSessionTokenInput *sts.GetSessionTokenInput
+	newSessionInput      []*aws.Config
 )
 
 type awsVaultTest struct {
@@ -57,23 +69,34 @@ func setupTestCase() {
 		SourceIdentity: &sourceIdentityString,
 	}
 	getSessionTokenReturnValue = &sts.GetSessionTokenOutput{}
+	getCallerIdentityReturnValue = &sts.GetCallerIdentityOutput{
+		Account: aws.String("000000000000"),
+		Arn:     aws.String("arn:aws:iam::000000000000:user/test"),
+	}
+	stsErrorReturnValue = nil
+	testErrorMessage = "Test error"
 
 	getAccessKeyIdCallCount = 0
 	getSecretAccessKeyCallCount = 0
 	getOtpCallCount = 0
 	assumeRoleCallCount = 0
 	getSessionTokenCallCount = 0
+	getCallerIdentityCallCount = 0
 
 	assumeRoleInput = nil
 	getSessionTokenInput = nil
+	newSessionInput = nil
 }
 
 func (stsApiTest) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
 	assumeRoleInput = input
 
 	assumeRoleCallCount++
+	if stsErrorReturnValue != nil {
+		return nil, stsErrorReturnValue
+	}
 	if assumeRoleReturnValue == nil {
-		return nil, fmt.Errorf("Test error")
+		return nil, errors.New(testErrorMessage)
 	}
 	return assumeRoleReturnValue, nil
 }
@@ -82,16 +105,27 @@ func (stsApiTest) GetSessionToken(input *sts.GetSessionTokenInput) (*sts.GetSess
 	getSessionTokenInput = input
 
 	getSessionTokenCallCount++
+	if stsErrorReturnValue != nil {
+		return nil, stsErrorReturnValue
+	}
 	if getSessionTokenReturnValue == nil {
-		return nil, fmt.Errorf("Test error")
+		return nil, errors.New(testErrorMessage)
 	}
 	return getSessionTokenReturnValue, nil
 }
 
+func (stsApiTest) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	getCallerIdentityCallCount++
+	if stsErrorReturnValue != nil {
+		return nil, stsErrorReturnValue
+	}
+	return getCallerIdentityReturnValue, nil
+}
+
 func (awsVaultTest) GetAccessKeyId() (string, error) {
 	getAccessKeyIdCallCount++
 	if getAccessKeyIdReturnValue == nil {
-		return "", fmt.Errorf("Test error")
+		return "", errors.New(testErrorMessage)
 	}
 	return *getAccessKeyIdReturnValue, nil
 }
@@ -99,7 +133,7 @@ func (awsVaultTest) GetAccessKeyId() (string, error) {
 func (awsVaultTest) GetSecretAccessKey() (string, error) {
 	getSecretAccessKeyCallCount++
 	if getSecretAccessKeyReturnValue == nil {
-		return "", fmt.Errorf("Test error")
+		return "", errors.New(testErrorMessage)
 	}
 	return *getSecretAccessKeyReturnValue, nil
 }
@@ -107,12 +141,13 @@ func (awsVaultTest) GetSecretAccessKey() (string, error) {
 func (awsVaultTest) GetOTP() (string, error) {
 	getOtpCallCount++
 	if getOtpReturnValue == nil {
-		return "", fmt.Errorf("Test error")
+		return "", errors.New(testErrorMessage)
 	}
 	return *getOtpReturnValue, nil
 }
 
 func (opAwsInputTest) NewSession(cfgs ...*aws.Config) *session.Session {
+	newSessionInput = cfgs
 	return session.New()
 }
 
@@ -159,6 +194,31 @@ func TestUsingAssumeRoleWithCorrectAssumeRoleInput(t *testing.T) {
 	assert.Nilf(assumeRoleInput.SerialNumber, "SerialNumber should be 'nil' - Acutal: %s", assumeRoleInput.SerialNumber)
 }
 
+func TestUsingSettings(t *testing.T) {
+	setupTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	client.AssumeRole("test-assume-role")
+	client.UseSessionName("test-session")
+	client.UseRegion("eu-central-1")
+	client.UseDuration(2 * time.Hour)
+	_, err := client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal("test-session", *assumeRoleInput.RoleSessionName)
+	assert.Equal(int64(7200), *assumeRoleInput.DurationSeconds)
+	assert.Equal("eu-central-1", *newSessionInput[0].Region)
+
+	client = opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	client.UseDuration(12 * time.Hour)
+	_, err = client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal(int64(43200), *getSessionTokenInput.DurationSeconds)
+	assert.Nil(newSessionInput[0].Region, "Without a region the default region of the SDK should be used")
+}
+
 func TestUsingAssumeRoleAndMfaWithCorrectAssumeRoleInput(t *testing.T) {
 	setupTestCase()
 	assert := assert.New(t)
@@ -359,3 +419,291 @@ func TestUsingGenerateSessionTokenAndMfaWithAccessKeyIdError(t *testing.T) {
 	assert.Equal(t, 1, getAccessKeyIdCallCount, "GetAccessKeyId should be called")
 	assert.ErrorContains(t, err, "Test error")
 }
+
+func TestUsingSourceCredentialsForAssumeRole(t *testing.T) {
+	setupTestCase()
+	assert := assert.New(t)
+	t.Helper()
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+
+	client.AssumeRole("test-assume-role")
+	client.UseSourceCredentials(&sts.Credentials{
+		AccessKeyId:     aws.String("session-access-key-id"),
+		SecretAccessKey: aws.String("session-secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+	})
+	_, err := client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal(1, assumeRoleCallCount, "AssumeRole should called one time")
+	assert.Equal("test-assume-role", *assumeRoleInput.RoleArn)
+	assert.Nil(assumeRoleInput.SerialNumber, "Without MFA device no code should be sent")
+	assert.Equal(0, getOtpCallCount, "GetOtp should not be called")
+	assert.Equal(0, getAccessKeyIdCallCount, "GetAccessKeyId should not be called")
+	assert.Equal(0, getSecretAccessKeyCallCount, "GetSecretAccessKey should not be called")
+
+	client.UseMFA("test-mfa")
+	_, err = client.GetCredentials()
+
+	assert.Nil(err)
+	assert.Equal("test-mfa", *assumeRoleInput.SerialNumber, "The MFA device of a chained role is used to assume the role")
+	assert.NotNil(assumeRoleInput.TokenCode)
+	assert.Equal(1, getOtpCallCount)
+}
+
+func TestUsingSourceCredentialsWithoutAssumeRole(t *testing.T) {
+	setupTestCase()
+	t.Helper()
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+
+	client.UseSourceCredentials(&sts.Credentials{
+		AccessKeyId:     aws.String("session-access-key-id"),
+		SecretAccessKey: aws.String("session-secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+	})
+	_, err := client.GetCredentials()
+
+	assert.ErrorContains(t, err, "assume a role")
+	assert.Equal(t, 0, getSessionTokenCallCount, "GetSessionToken should not be called")
+	assert.Equal(t, 0, assumeRoleCallCount, "AssumeRole should not be called")
+}
+
+func TestStagesOfErrors(t *testing.T) {
+	testCasesStages := []struct {
+		name          string
+		assumeRole    string
+		setup         func()
+		expectedStage string
+		expectedKind  error
+	}{
+		{
+			name:          "access key id",
+			setup:         func() { getAccessKeyIdReturnValue = nil },
+			expectedStage: opaws.STAGE_VAULT,
+		},
+		{
+			name:          "secret access key",
+			setup:         func() { getSecretAccessKeyReturnValue = nil },
+			expectedStage: opaws.STAGE_VAULT,
+		},
+		{
+			name:          "otp",
+			setup:         func() { getOtpReturnValue = nil },
+			expectedStage: opaws.STAGE_MFA,
+		},
+		{
+			name: "invalid mfa code",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code.", nil)
+			},
+			expectedStage: opaws.STAGE_MFA,
+			expectedKind:  opaws.ErrMFARejected,
+		},
+		{
+			name: "session token",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("InvalidClientTokenId", "The security token included in the request is invalid.", nil)
+			},
+			expectedStage: opaws.STAGE_STS,
+		},
+		{
+			name:       "role trust",
+			assumeRole: "test-assume-role",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("AccessDenied", "User is not authorized to perform: sts:AssumeRole", nil)
+			},
+			expectedStage: opaws.STAGE_ROLE_TRUST,
+			expectedKind:  opaws.ErrRoleTrust,
+		},
+		{
+			name: "access denied",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("AccessDenied", "User is not authorized to perform: sts:GetSessionToken", nil)
+			},
+			expectedStage: opaws.STAGE_STS,
+			expectedKind:  opaws.ErrAccessDenied,
+		},
+		{
+			name: "network",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("RequestError", "send request failed", fmt.Errorf("dial tcp: lookup sts.amazonaws.com: no such host"))
+			},
+			expectedStage: opaws.STAGE_STS,
+			expectedKind:  opaws.ErrNetwork,
+		},
+		{
+			name:          "assume role",
+			assumeRole:    "test-assume-role",
+			setup:         func() { assumeRoleReturnValue = nil },
+			expectedStage: opaws.STAGE_STS,
+		},
+	}
+
+	for _, v := range testCasesStages {
+		t.Run(v.name, func(t *testing.T) {
+			setupTestCase()
+			v.setup()
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			client.AssumeRole(v.assumeRole)
+			client.UseMFA("test-mfa")
+			_, err := client.GetCredentials()
+
+			assert.NotNil(t, err)
+			assert.Equal(t, v.expectedStage, opaws.GetStage(err))
+			if v.expectedKind != nil {
+				assert.ErrorIs(t, err, v.expectedKind)
+			}
+		})
+	}
+}
+
+func TestRoleTrustIsAccessDenied(t *testing.T) {
+	setupTestCase()
+	stsErrorReturnValue = awserr.New("AccessDenied", "User is not authorized to perform: sts:AssumeRole", nil)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	client.AssumeRole("test-assume-role")
+	_, err := client.GetCredentials()
+
+	assert.ErrorIs(t, err, opaws.ErrAccessDenied, "A denied role trust should still be an access denied")
+	assert.NotErrorIs(t, err, opaws.ErrMFARejected)
+}
+
+func TestGetCallerIdentity(t *testing.T) {
+	setupTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	identity, err := client.GetCallerIdentity(&sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+	})
+
+	assert.Nil(err)
+	assert.Equal(1, getCallerIdentityCallCount)
+	assert.Equal("000000000000", *identity.Account)
+	assert.Equal(0, getAccessKeyIdCallCount, "The credentials inside of the vault should not be used")
+
+	stsErrorReturnValue = fmt.Errorf("Test error")
+	_, err = client.GetCallerIdentity(&sts.Credentials{
+		AccessKeyId:     aws.String("access-key-id"),
+		SecretAccessKey: aws.String("secret-access-key"),
+	})
+	assert.ErrorContains(err, "Test error")
+	assert.Equal(opaws.STAGE_STS, opaws.GetStage(err))
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	sourceCredentials := &sts.Credentials{
+		AccessKeyId:     aws.String("session-access-key-id"),
+		SecretAccessKey: aws.String("session-secret-access-key"),
+		SessionToken:    aws.String("session-token-default"),
+	}
+	vaultSecrets := []string{"secret-access-key-default", "otp-default"}
+
+	testCasesRedaction := []struct {
+		name              string
+		assumeRole        string
+		mfa               string
+		sourceCredentials *sts.Credentials
+		secrets           []string
+		setup             func()
+	}{
+		{
+			name:    "otp",
+			secrets: []string{"secret-access-key-default"},
+			mfa:     "test-mfa",
+			setup:   func() { getOtpReturnValue = nil },
+		},
+		{
+			name:    "session token",
+			secrets: vaultSecrets,
+			mfa:     "test-mfa",
+			setup:   func() { getSessionTokenReturnValue = nil },
+		},
+		{
+			name:       "assume role",
+			secrets:    vaultSecrets,
+			assumeRole: "test-assume-role",
+			mfa:        "test-mfa",
+			setup:      func() { assumeRoleReturnValue = nil },
+		},
+		{
+			name:       "invalid mfa code",
+			secrets:    vaultSecrets,
+			assumeRole: "test-assume-role",
+			mfa:        "test-mfa",
+			setup: func() {
+				stsErrorReturnValue = awserr.New("AccessDenied", "MultiFactorAuthentication failed with invalid MFA one time pass code otp-default for secret-access-key-default", nil)
+			},
+		},
+		{
+			name:              "source credentials",
+			assumeRole:        "test-assume-role",
+			sourceCredentials: sourceCredentials,
+			secrets:           []string{"session-secret-access-key", "session-token-default"},
+			setup: func() {
+				stsErrorReturnValue = awserr.New("InvalidClientTokenId", "The security token session-token-default included in the request is invalid", nil)
+			},
+		},
+	}
+
+	for _, v := range testCasesRedaction {
+		t.Run(v.name, func(t *testing.T) {
+			setupTestCase()
+			redact.Reset()
+			defer redact.Reset()
+			// Every failing call reports all secrets, that have been fetched before.
+			testErrorMessage = "Test error " + strings.Join(v.secrets, " ")
+			v.setup()
+
+			output := &bytes.Buffer{}
+			logger.SetDefault(logger.New(logger.LEVEL_DEBUG, output, output))
+			defer logger.SetDefault(logger.New(logger.LEVEL_ERROR, nil, nil))
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			client.AssumeRole(v.assumeRole)
+			client.UseMFA(v.mfa)
+			if v.sourceCredentials != nil {
+				client.UseSourceCredentials(v.sourceCredentials)
+			}
+			_, err := client.GetCredentials()
+			logger.Error(err.Error())
+
+			assert.NotNil(t, err)
+			for _, secret := range v.secrets {
+				assert.NotContains(t, err.Error(), secret)
+				assert.NotContains(t, output.String(), secret)
+			}
+			assert.Contains(t, err.Error(), redact.REDACTED)
+		})
+	}
+}
+
+func TestGeneratedCredentialsAreRedacted(t *testing.T) {
+	setupTestCase()
+	redact.Reset()
+	defer redact.Reset()
+	assumeRoleReturnValue = &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("assumed-access-key-id"),
+			SecretAccessKey: aws.String("assumed-secret-access-key"),
+			SessionToken:    aws.String("assumed-session-token"),
+		},
+	}
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	client.AssumeRole("test-assume-role")
+	_, err := client.GetCredentials()
+	assert.Nil(t, err)
+
+	stsErrorReturnValue = fmt.Errorf("Test error assumed-secret-access-key assumed-session-token")
+	_, err = client.GetCallerIdentity(assumeRoleReturnValue.Credentials)
+
+	assert.NotContains(t, err.Error(), "assumed-secret-access-key")
+	assert.NotContains(t, err.Error(), "assumed-session-token")
+	assert.Equal(t, opaws.STAGE_STS, opaws.GetStage(err), "The stage should survive the redaction")
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 )
@@ -11,6 +13,7 @@ import (
 type OpAWSInput interface {
 	NewSts(p client.ConfigProvider, cfgs ...*aws.Config) stsiface.STSAPI
 	NewSession(cfgs ...*aws.Config) *session.Session
+	NewIam(p client.ConfigProvider, cfgs ...*aws.Config) iamiface.IAMAPI
 }
 
 type OpAwsDefaultInput struct {
@@ -24,3 +27,7 @@ func (OpAwsDefaultInput) NewSts(p client.ConfigProvider, cfgs ...*aws.Config) st
 func (OpAwsDefaultInput) NewSession(cfgs ...*aws.Config) *session.Session {
 	return session.New(cfgs...)
 }
+
+func (OpAwsDefaultInput) NewIam(p client.ConfigProvider, cfgs ...*aws.Config) iamiface.IAMAPI {
+	return iam.New(p, cfgs...)
+}
@@ -0,0 +1,410 @@
+package opaws
+
+import (
+	"fmt"
+	"io/fs"
+	"nextunit/op2aws/config"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const DEFAULT_MFA_SESSION_DURATION = 12 * time.Hour
+
+type OpProfile struct {
+	Name                 string
+	Vault                string
+	Item                 string
+	AssumeRole           string
+	MFA                  string
+	LabelAccessKey       string
+	LabelSecretAccessKey string
+	MFASession           bool
+	Preset               string `json:",omitempty"`
+
+	// The vault and the item of a chained profile are the ones of the first profile of the chain.
+	SourceProfile string     `json:",omitempty"`
+	Source        *OpProfile `json:",omitempty"`
+
+	// Resolved from the settings file, the environment and the flags.
+	SessionName string        `json:",omitempty"`
+	Region      string        `json:",omitempty"`
+	Duration    time.Duration `json:",omitempty"`
+	// The duration of the MFA session of --mfa-session.
+	MFASessionDuration time.Duration `json:",omitempty"`
+	CacheDir           string        `json:",omitempty"`
+}
+
+// splitArn returns the account and the resource of an ARN like arn:aws:iam::123456789012:role/Admin.
+func splitArn(arn string) (string, string, bool) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return "", "", false
+	}
+
+	return parts[4], parts[5], true
+}
+
+// GetAccountId returns the account of the role or, without a role, of the MFA device.
+func (p OpProfile) GetAccountId() string {
+	for _, arn := range []string{p.AssumeRole, p.MFA} {
+		if account, _, ok := splitArn(arn); ok {
+			return account
+		}
+	}
+
+	return ""
+}
+
+// GetRoleName returns the name of the assumed role without its path.
+func (p OpProfile) GetRoleName() string {
+	_, resource, ok := splitArn(p.AssumeRole)
+	if !ok {
+		return p.AssumeRole
+	}
+
+	return resource[strings.LastIndex(resource, "/")+1:]
+}
+
+// GetMFASessionProfile returns the profile of the MFA session, that the roles of the credentials share.
+func (p OpProfile) GetMFASessionProfile() *OpProfile {
+	p.AssumeRole = ""
+	p.MFASession = false
+	p.Duration = p.MFASessionDuration
+	if p.Duration == 0 {
+		p.Duration = DEFAULT_MFA_SESSION_DURATION
+	}
+
+	return &p
+}
+
+func profileSectionName(name string) string {
+	if name == "default" {
+		return name
+	}
+
+	return "profile " + name
+}
+
+func isOp2awsCommand(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+
+	binary := strings.TrimSuffix(filepath.Base(args[0]), ".exe")
+	return binary == config.COMMAND_ROOT && args[1] == config.COMMAND_CLI
+}
+
+// getLegacyArgs returns the arguments of a credential_process of older versions, that wraps the command in `sh -c`.
+func getLegacyArgs(value string) ([]string, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "sh -c ") {
+		return nil, false
+	}
+
+	args, err := splitPosixCommandLine(value)
+	if err != nil || len(args) != 3 || args[0] != "sh" || args[1] != "-c" {
+		return nil, false
+	}
+
+	args, err = splitPosixCommandLine(args[2])
+	if err != nil {
+		return nil, false
+	}
+
+	return args, true
+}
+
+// ParseCredentialProcess decodes the op2aws arguments of a credential_process. Labels, that are not set, are left empty.
+func ParseCredentialProcess(value string) (*OpProfile, error) {
+	args, ok := getLegacyArgs(value)
+	if !ok {
+		var err error
+		args, err = SplitCommandLine(value, CREDENTIAL_PROCESS_OS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !isOp2awsCommand(args) {
+		return nil, fmt.Errorf("The credential_process is not using %s %s", config.COMMAND_ROOT, config.COMMAND_CLI)
+	}
+
+	profile := &OpProfile{}
+	flags := pflag.NewFlagSet(config.COMMAND_CLI, pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.StringVarP(&profile.AssumeRole, "assume-role", "a", "", "")
+	flags.StringVarP(&profile.MFA, "mfa", "m", "", "")
+	flags.StringVarP(&profile.LabelAccessKey, "label-accesskey", "k", "", "")
+	flags.StringVarP(&profile.LabelSecretAccessKey, "label-secret-accesskey", "s", "", "")
+	flags.BoolVar(&profile.MFASession, "mfa-session", false, "")
+	flags.StringVar(&profile.Preset, "preset", "", "")
+	flags.StringVar(&profile.Name, "profile-name", "", "")
+	flags.BoolP("force", "f", false, "")
+	flags.BoolP("export", "e", false, "")
+
+	if err := flags.Parse(args[2:]); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() != 2 {
+		return nil, fmt.Errorf("The credential_process needs exactly a vault and an item, got: %s", strings.Join(flags.Args(), " "))
+	}
+
+	profile.Vault = flags.Arg(0)
+	profile.Item = flags.Arg(1)
+
+	return profile, nil
+}
+
+func getProfileName(sectionName string) (string, bool) {
+	if sectionName == "default" {
+		return sectionName, true
+	}
+
+	if name, ok := strings.CutPrefix(sectionName, "profile "); ok {
+		return strings.TrimSpace(name), true
+	}
+
+	return "", false
+}
+
+func parseProfileSection(name string, section *iniSection) (*OpProfile, error) {
+	credentialProcess, ok := section.get("credential_process")
+	if !ok {
+		return nil, fmt.Errorf("The profile %s has no credential_process configured", name)
+	}
+
+	profile, err := ParseCredentialProcess(credentialProcess)
+	if err != nil {
+		return nil, fmt.Errorf("The profile %s is not an %s profile: %w", name, config.COMMAND_ROOT, err)
+	}
+
+	profile.Name = name
+	return profile, nil
+}
+
+// getProfile reads the profile of the config file. The chain is used to detect cycles of source profiles.
+func getProfile(file *iniFile, name, path string, chain []string) (*OpProfile, error) {
+	for _, n := range chain {
+		if n == name {
+			return nil, fmt.Errorf("The source_profile of the profiles is a cycle: %s", strings.Join(append(chain, name), " -> "))
+		}
+	}
+
+	section := file.section(profileSectionName(name))
+	if section == nil {
+		return nil, fmt.Errorf("The profile %s does not exist in %s", name, path)
+	}
+
+	sourceProfile, hasSource := section.get(KEY_SOURCE_PROFILE)
+	roleArn, hasRole := section.get(KEY_ROLE_ARN)
+	if !hasSource || !hasRole {
+		return parseProfileSection(name, section)
+	}
+
+	source, err := getProfile(file, sourceProfile, path, append(chain, name))
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, _ := section.get(KEY_MFA_SERIAL)
+	return &OpProfile{
+		Name:                 name,
+		Vault:                source.Vault,
+		Item:                 source.Item,
+		AssumeRole:           roleArn,
+		MFA:                  mfa,
+		LabelAccessKey:       source.LabelAccessKey,
+		LabelSecretAccessKey: source.LabelSecretAccessKey,
+		SourceProfile:        sourceProfile,
+		Source:               source,
+	}, nil
+}
+
+// GetProfile reads the profile from the config file and decodes the op2aws
+// arguments of its credential_process or resolves its source profiles.
+func (c AWSConfig) GetProfile(name string) (*OpProfile, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return getProfile(file, name, c.path, nil)
+}
+
+// GetProfiles returns all profiles of the config file, that use op2aws as credential_process or as source profile.
+func (c AWSConfig) GetProfiles() ([]*OpProfile, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []*OpProfile{}
+	for _, section := range file.sections {
+		name, ok := getProfileName(section.name)
+		if !ok || file.section(section.name) != section {
+			continue
+		}
+
+		profile, err := getProfile(file, name, c.path, nil)
+		if err == nil {
+			profiles = append(profiles, profile)
+		}
+	}
+
+	return profiles, nil
+}
+
+// getFileMode returns the permissions of the config file.
+func (c AWSConfig) getFileMode() fs.FileMode {
+	info, err := c.client.Stat(c.path)
+	if err != nil {
+		return 0644
+	}
+
+	return info.Mode().Perm()
+}
+
+// getOp2awsSection returns the section of the profile, when it uses op2aws as credential_process or as source profile.
+func getOp2awsSection(file *iniFile, name, path string) (*iniSection, error) {
+	if _, err := getProfile(file, name, path, nil); err != nil {
+		return nil, err
+	}
+
+	return file.section(profileSectionName(name)), nil
+}
+
+// RemoveProfile cuts the section of the profile out of the config file. Only profiles using op2aws are removed.
+func (c AWSConfig) RemoveProfile(name string) error {
+	file, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	if _, err := getOp2awsSection(file, name, c.path); err != nil {
+		return err
+	}
+
+	file.removeSection(profileSectionName(name))
+	return c.write(file, c.getFileMode())
+}
+
+// UpdateProfile replaces the credential_process or the source profile of the profile and keeps the other keys.
+func (c AWSConfig) UpdateProfile(profile *OpProfile) error {
+	file, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	section, err := getOp2awsSection(file, profile.Name, c.path)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range profile.getKeys() {
+		if key[1] == "" {
+			section.remove(key[0])
+		} else {
+			section.add(key[0], key[1])
+		}
+	}
+
+	if profile.SourceProfile != "" {
+		if _, err := getProfile(file, profile.Name, c.path, nil); err != nil {
+			return err
+		}
+	}
+
+	return c.write(file, c.getFileMode())
+}
+
+// ProfileMigration is the change of the credential_process of a profile.
+type ProfileMigration struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// hasProfileName returns whether the op2aws arguments contain --profile-name or --profile in front of a --.
+func hasProfileName(args []string) bool {
+	for _, arg := range args[2:] {
+		if arg == "--" {
+			return false
+		}
+
+		for _, flag := range []string{"--profile-name", "--profile", "-p"} {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// migrate changes the credential_process of every op2aws profile, that uses `sh -c` or has no --profile-name.
+func migrate(file *iniFile) []ProfileMigration {
+	migrations := []ProfileMigration{}
+	for _, section := range file.sections {
+		name, ok := getProfileName(section.name)
+		if !ok || file.section(section.name) != section {
+			continue
+		}
+
+		credentialProcess, ok := section.get("credential_process")
+		if !ok {
+			continue
+		}
+
+		args, legacy := getLegacyArgs(credentialProcess)
+		if !legacy {
+			var err error
+			if args, err = SplitCommandLine(credentialProcess, CREDENTIAL_PROCESS_OS); err != nil {
+				continue
+			}
+		}
+
+		if !isOp2awsCommand(args) {
+			continue
+		}
+
+		if !hasProfileName(args) {
+			args = append(args[:2], append([]string{"--profile-name", name}, args[2:]...)...)
+		} else if !legacy {
+			continue
+		}
+
+		migration := ProfileMigration{Name: name, Old: credentialProcess, New: JoinCommandLine(args, CREDENTIAL_PROCESS_OS)}
+		section.set("credential_process", migration.New)
+		migrations = append(migrations, migration)
+	}
+
+	return migrations
+}
+
+// GetMigrations returns the changes of MigrateProfiles without writing them.
+func (c AWSConfig) GetMigrations() ([]ProfileMigration, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate(file), nil
+}
+
+// MigrateProfiles rewrites the op2aws profiles, that use `sh -c` or have no --profile-name.
+func (c AWSConfig) MigrateProfiles() ([]ProfileMigration, error) {
+	file, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := migrate(file)
+	if len(migrations) == 0 {
+		return migrations, nil
+	}
+
+	return migrations, c.write(file, c.getFileMode())
+}
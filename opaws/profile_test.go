@@ -0,0 +1,390 @@
+package opaws_test
+
+import (
+	"fmt"
+	"io/fs"
+	"nextunit/op2aws/awsvault"
+	"nextunit/op2aws/opaws"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCredentialProcess(t *testing.T) {
+	t.Helper()
+
+	for i, v := range testCases {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			assert := assert.New(t)
+
+			body := opaws.GetProfileBody(
+				v.profileName,
+				v.vault,
+				v.item,
+				v.assumeRole,
+				v.mfa,
+				v.labelAccessKey,
+				v.labelSecretAccessKey,
+			)
+			_, credentialProcess, _ := strings.Cut(body, "credential_process = ")
+
+			profile, err := opaws.ParseCredentialProcess(credentialProcess)
+
+			// The default labels are not written, so they are left empty for the settings.
+			expectedLabelAccessKey := v.labelAccessKey
+			if expectedLabelAccessKey == awsvault.AWS_ACCESS_KEY_FIELD_DEFAULT {
+				expectedLabelAccessKey = ""
+			}
+			expectedLabelSecretAccessKey := v.labelSecretAccessKey
+			if expectedLabelSecretAccessKey == awsvault.AWS_SECRET_ACCESS_KEY_FIELD_DEFAULT {
+				expectedLabelSecretAccessKey = ""
+			}
+
+			assert.Nil(err)
+			assert.Equal(&opaws.OpProfile{
+				Name:                 v.profileName,
+				Vault:                v.vault,
+				Item:                 v.item,
+				AssumeRole:           v.assumeRole,
+				MFA:                  v.mfa,
+				LabelAccessKey:       expectedLabelAccessKey,
+				LabelSecretAccessKey: expectedLabelSecretAccessKey,
+			}, profile)
+		})
+	}
+}
+
+func TestParseCredentialProcessWithoutShell(t *testing.T) {
+	profile, err := opaws.ParseCredentialProcess("/usr/local/bin/op2aws cli 'my vault' \"AWS \\\"prod\\\"\" --mfa=test-mfa -f")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "my vault", profile.Vault)
+	assert.Equal(t, "AWS \"prod\"", profile.Item)
+	assert.Equal(t, "test-mfa", profile.MFA)
+}
+
+func TestParseCredentialProcessErrors(t *testing.T) {
+	for i, v := range []string{
+		"aws-vault exec test --json",
+		"sh -c '\"op2aws\" \"cli\" \"test-vault\"'",
+		"op2aws cli 'test-vault test-item",
+	} {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			profile, err := opaws.ParseCredentialProcess(v)
+
+			assert.NotNil(t, err)
+			assert.Nil(t, profile)
+		})
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	readFileReturnValue = []byte("[default]\nregion = eu-central-1\n" + opaws.GetProfileBody("test-profile", "test-vault", "test-item", "testAssumeRole", "testMfa", "", "") + "\n\n[profile other]\nregion = us-east-1\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	profile, err := client.GetProfile("test-profile")
+
+	assert.Nil(err)
+	assert.Equal(1, readFileCallCount, "client.ReadFile should be called one time")
+	assert.Equal("test-path", readFileInput)
+	assert.Equal(&opaws.OpProfile{
+		Name:       "test-profile",
+		Vault:      "test-vault",
+		Item:       "test-item",
+		AssumeRole: "testAssumeRole",
+		MFA:        "testMfa",
+	}, profile)
+
+	_, err = client.GetProfile("other")
+	assert.ErrorContains(err, "no credential_process")
+
+	_, err = client.GetProfile("missing")
+	assert.ErrorContains(err, "does not exist")
+
+	readFileReturnValue = nil
+	_, err = client.GetProfile("test-profile")
+	assert.ErrorContains(err, "test error ReadFile")
+}
+
+func TestParseCredentialProcessWithMFASession(t *testing.T) {
+	profile := &opaws.OpProfile{
+		Name:       "test-profile",
+		Vault:      "test-vault",
+		Item:       "test-item",
+		AssumeRole: "testAssumeRole",
+		MFA:        "testMfa",
+		MFASession: true,
+	}
+
+	body := profile.GetBody()
+	assert.Equal(t, "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -a testAssumeRole -m testMfa --mfa-session --profile-name test-profile", body)
+
+	_, credentialProcess, _ := strings.Cut(body, "credential_process = ")
+	parsedProfile, err := opaws.ParseCredentialProcess(credentialProcess)
+	parsedProfile.Name = profile.Name
+
+	assert.Nil(t, err)
+	assert.Equal(t, profile, parsedProfile)
+}
+
+func TestGetProfiles(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	readFileReturnValue = []byte("[default]\nregion = eu-central-1\n" +
+		opaws.GetProfileBody("first", "test-vault", "test-item", "", "", "", "") +
+		"\n\n[profile other]\ncredential_process = aws-vault exec other --json\n" +
+		opaws.GetProfileBody("second", "test-vault", "test-item-2", "testAssumeRole", "", "", "") + "\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	profiles, err := client.GetProfiles()
+
+	assert.Nil(err)
+	assert.Len(profiles, 2, "Only profiles using op2aws should be returned")
+	assert.Equal("first", profiles[0].Name)
+	assert.Equal("test-item", profiles[0].Item)
+	assert.Equal("second", profiles[1].Name)
+	assert.Equal("testAssumeRole", profiles[1].AssumeRole)
+
+	readFileReturnValue = nil
+	_, err = client.GetProfiles()
+	assert.ErrorContains(err, "test error ReadFile")
+}
+
+func TestParseCredentialProcessWithPreset(t *testing.T) {
+	profile := &opaws.OpProfile{
+		Name:           "test-profile",
+		Vault:          "test-vault",
+		Item:           "test-item",
+		LabelAccessKey: "testLabelAccessKey",
+		Preset:         "test-preset",
+	}
+
+	body := profile.GetBody()
+	assert.Equal(t, "\n\n[profile test-profile]\n    credential_process = op2aws cli test-vault test-item -k testLabelAccessKey --preset test-preset --profile-name test-profile", body)
+
+	_, credentialProcess, _ := strings.Cut(body, "credential_process = ")
+	parsedProfile, err := opaws.ParseCredentialProcess(credentialProcess)
+	parsedProfile.Name = profile.Name
+
+	assert.Nil(t, err)
+	assert.Equal(t, profile, parsedProfile)
+}
+
+func TestRemoveProfile(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[default]\nregion = eu-central-1" +
+		opaws.GetProfileBody("test-profile", "test-vault", "test-item", "", "", "", "") +
+		"\nregion = us-east-1\n\n[profile other]\ncredential_process = aws-vault exec other --json\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	err := client.RemoveProfile("test-profile")
+
+	assert.Nil(err)
+	assert.Equal("[default]\nregion = eu-central-1\n\n[profile other]\ncredential_process = aws-vault exec other --json\n", string(writeFileInput[0].data))
+	assert.Equal(fs.FileMode(0640), writeFileInput[0].perm, "The permissions of the file should be kept")
+
+	err = client.RemoveProfile("other")
+	assert.ErrorContains(err, "is not an op2aws profile")
+
+	err = client.RemoveProfile("missing")
+	assert.ErrorContains(err, "does not exist")
+	assert.Equal(1, writeFileCallCount)
+}
+
+func TestUpdateProfile(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[profile test-profile]\n  credential_process = op2aws cli old-vault old-item\n  region = us-east-1\n\n[profile other]\nregion = eu-central-1\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	profile := &opaws.OpProfile{Name: "test-profile", Vault: "test-vault", Item: "test-item", MFA: "testMfa"}
+	err := client.UpdateProfile(profile)
+
+	assert.Nil(err)
+	assert.Equal("[profile test-profile]\n  credential_process = "+profile.GetCredentialProcess()+"\n  region = us-east-1\n\n[profile other]\nregion = eu-central-1\n", string(writeFileInput[0].data))
+
+	err = client.UpdateProfile(&opaws.OpProfile{Name: "other"})
+	assert.ErrorContains(err, "has no credential_process")
+	assert.Equal(1, writeFileCallCount)
+}
+
+func TestParseLegacyCredentialProcess(t *testing.T) {
+	for _, goos := range COMMAND_LINE_OS {
+		setCredentialProcessOS(t, goos)
+
+		profile, err := opaws.ParseCredentialProcess("sh -c '\"op2aws\" \"cli\" \"my vault\" \"test-item\" \"-a\" \"testAssumeRole\" \"-m\" \"testMfa\" \"--mfa-session\"'")
+
+		assert.Nil(t, err, goos)
+		assert.Equal(t, &opaws.OpProfile{
+			Vault:      "my vault",
+			Item:       "test-item",
+			AssumeRole: "testAssumeRole",
+			MFA:        "testMfa",
+			MFASession: true,
+		}, profile, goos)
+	}
+}
+
+func TestMigrateProfiles(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	setCredentialProcessOS(t, "linux")
+
+	readFileReturnValue = []byte("[profile legacy]\n    credential_process = sh -c '\"op2aws\" \"cli\" \"my vault\" \"test-item\" \"-m\" \"auto\" \"-x\"'\n    region = us-east-1\n\n" +
+		"[profile unnamed]\ncredential_process = op2aws cli test-vault test-item\n\n" +
+		"[profile current]\ncredential_process = op2aws cli test-vault test-item --profile-name current\n\n" +
+		"[profile chained]\ncredential_process = op2aws cli --profile chained\nop2aws_source_profile = current\nop2aws_role_arn = role\n\n" +
+		"[profile other]\ncredential_process = sh -c 'aws-vault exec other --json'\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	migrations, err := client.GetMigrations()
+	assert.Nil(err)
+	assert.Equal([]opaws.ProfileMigration{{
+		Name: "legacy",
+		Old:  "sh -c '\"op2aws\" \"cli\" \"my vault\" \"test-item\" \"-m\" \"auto\" \"-x\"'",
+		New:  "op2aws cli --profile-name legacy 'my vault' test-item -m auto -x",
+	}, {
+		Name: "unnamed",
+		Old:  "op2aws cli test-vault test-item",
+		New:  "op2aws cli --profile-name unnamed test-vault test-item",
+	}}, migrations)
+	assert.Equal(0, writeFileCallCount, "GetMigrations should not write the file")
+
+	migrations, err = client.MigrateProfiles()
+	assert.Nil(err)
+	assert.Len(migrations, 2)
+	assert.Equal("[profile legacy]\n    credential_process = op2aws cli --profile-name legacy 'my vault' test-item -m auto -x\n    region = us-east-1\n\n"+
+		"[profile unnamed]\ncredential_process = op2aws cli --profile-name unnamed test-vault test-item\n\n"+
+		"[profile current]\ncredential_process = op2aws cli test-vault test-item --profile-name current\n\n"+
+		"[profile chained]\ncredential_process = op2aws cli --profile chained\nop2aws_source_profile = current\nop2aws_role_arn = role\n\n"+
+		"[profile other]\ncredential_process = sh -c 'aws-vault exec other --json'\n", string(writeFileInput[0].data))
+
+	profile, err := client.GetProfile("unnamed")
+	assert.Nil(err)
+	assert.Equal("unnamed", profile.Name)
+
+	readFileReturnValue = []byte("[profile current]\ncredential_process = op2aws cli test-vault test-item --profile-name current\n")
+	migrations, err = client.MigrateProfiles()
+	assert.Nil(err)
+	assert.Empty(migrations)
+	assert.Equal(1, writeFileCallCount, "Nothing should be written without profiles to migrate")
+}
+
+func TestGetProfileWithSourceProfile(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	readFileReturnValue = []byte("[profile base]\ncredential_process = op2aws cli test-vault test-item -m testMfa -k testLabel\n\n" +
+		"[profile admin]\ncredential_process = op2aws cli --profile admin\nop2aws_source_profile = base\nop2aws_role_arn = arn:aws:iam::111111111111:role/Admin\n\n" +
+		"[profile readonly]\ncredential_process = op2aws cli --profile readonly\nop2aws_source_profile = admin\nop2aws_role_arn = arn:aws:iam::222222222222:role/ReadOnly\nop2aws_mfa_serial = otherMfa\n\n" +
+		"[profile first]\nop2aws_source_profile = second\nop2aws_role_arn = arn:aws:iam::111111111111:role/First\n\n" +
+		"[profile second]\nop2aws_source_profile = first\nop2aws_role_arn = arn:aws:iam::111111111111:role/Second\n\n" +
+		"[profile missing]\nop2aws_source_profile = unknown\nop2aws_role_arn = arn:aws:iam::111111111111:role/Missing\n\n" +
+		"[profile aws-cli]\nsource_profile = base\nrole_arn = arn:aws:iam::111111111111:role/Admin\nmfa_serial = testMfa\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	profile, err := client.GetProfile("readonly")
+
+	assert.Nil(err)
+	assert.Equal("readonly", profile.Name)
+	assert.Equal("admin", profile.SourceProfile)
+	assert.Equal("arn:aws:iam::222222222222:role/ReadOnly", profile.AssumeRole)
+	assert.Equal("otherMfa", profile.MFA)
+	assert.Equal("test-vault", profile.Vault, "The vault of the first profile of the chain should be used")
+	assert.Equal("testLabel", profile.LabelAccessKey)
+	assert.Equal("admin", profile.Source.Name)
+	assert.Equal("arn:aws:iam::111111111111:role/Admin", profile.Source.AssumeRole)
+	assert.Equal("base", profile.Source.Source.Name)
+	assert.Equal("testMfa", profile.Source.Source.MFA)
+	assert.Nil(profile.Source.Source.Source)
+
+	_, err = client.GetProfile("first")
+	assert.ErrorContains(err, "is a cycle: first -> second -> first")
+
+	_, err = client.GetProfile("missing")
+	assert.ErrorContains(err, "The profile unknown does not exist")
+
+	_, err = client.GetProfile("aws-cli")
+	assert.ErrorContains(err, "has no credential_process", "Chains of the AWS CLI are resolved by the AWS CLI and not by op2aws")
+
+	profiles, err := client.GetProfiles()
+	assert.Nil(err)
+	assert.Len(profiles, 3, "Profiles with a cycle or a missing source profile should not be returned")
+}
+
+func TestGetChainedProfileBody(t *testing.T) {
+	setCredentialProcessOS(t, "linux")
+
+	assert.Equal(t, "\n\n[profile admin]\n    credential_process = op2aws cli --profile admin\n    op2aws_source_profile = base\n    op2aws_role_arn = arn:aws:iam::111111111111:role/Admin",
+		opaws.GetChainedProfileBody("admin", "base", "arn:aws:iam::111111111111:role/Admin", ""))
+	assert.Equal(t, "\n\n[profile admin]\n    credential_process = op2aws cli --profile admin\n    op2aws_source_profile = base\n    op2aws_role_arn = arn:aws:iam::111111111111:role/Admin\n    op2aws_mfa_serial = testMfa",
+		opaws.GetChainedProfileBody("admin", "base", "arn:aws:iam::111111111111:role/Admin", "testMfa"))
+}
+
+func TestUpdateProfileWithSourceProfile(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	setCredentialProcessOS(t, "linux")
+	readFileReturnValue = []byte("[profile base]\ncredential_process = op2aws cli test-vault test-item\n\n" +
+		"[profile admin]\ncredential_process = op2aws cli test-vault test-item -a oldRole\nregion = us-east-1\n")
+	client := opaws.NewAwsConfig(&testAwsConfigMock{}, "test-path")
+
+	err := client.UpdateProfile(&opaws.OpProfile{Name: "admin", SourceProfile: "base", AssumeRole: "newRole", MFA: "testMfa"})
+
+	assert.Nil(err)
+	assert.Equal("[profile base]\ncredential_process = op2aws cli test-vault test-item\n\n"+
+		"[profile admin]\ncredential_process = op2aws cli --profile admin\nregion = us-east-1\nop2aws_source_profile = base\nop2aws_role_arn = newRole\nop2aws_mfa_serial = testMfa\n", string(writeFileInput[0].data))
+
+	readFileReturnValue = writeFileInput[0].data
+	err = client.UpdateProfile(&opaws.OpProfile{Name: "admin", Vault: "test-vault", Item: "test-item"})
+
+	assert.Nil(err)
+	assert.Equal("[profile base]\ncredential_process = op2aws cli test-vault test-item\n\n"+
+		"[profile admin]\ncredential_process = op2aws cli test-vault test-item --profile-name admin\nregion = us-east-1\n", string(writeFileInput[1].data))
+
+	err = client.UpdateProfile(&opaws.OpProfile{Name: "base", SourceProfile: "admin", AssumeRole: "role"})
+	assert.ErrorContains(err, "is a cycle")
+	assert.Equal(2, writeFileCallCount)
+}
+
+func TestGetAccountIdAndRoleName(t *testing.T) {
+	profile := opaws.OpProfile{AssumeRole: "arn:aws:iam::222222222222:role/team/Admin", MFA: "arn:aws:iam::111111111111:mfa/jane"}
+	assert.Equal(t, "222222222222", profile.GetAccountId())
+	assert.Equal(t, "Admin", profile.GetRoleName())
+
+	profile = opaws.OpProfile{MFA: "arn:aws:iam::111111111111:mfa/jane"}
+	assert.Equal(t, "111111111111", profile.GetAccountId(), "Without a role, the account of the MFA device should be used")
+	assert.Equal(t, "", profile.GetRoleName())
+
+	profile = opaws.OpProfile{MFA: opaws.MFA_AUTO}
+	assert.Equal(t, "", profile.GetAccountId())
+}
+
+func TestGetMFASessionProfile(t *testing.T) {
+	assert := assert.New(t)
+	profile := opaws.OpProfile{
+		Name:       "admin",
+		Vault:      "test-vault",
+		Item:       "test-item",
+		AssumeRole: "arn:aws:iam::222222222222:role/Admin",
+		MFA:        "arn:aws:iam::111111111111:mfa/jane",
+		MFASession: true,
+		Duration:   time.Hour,
+	}
+
+	session := profile.GetMFASessionProfile()
+	assert.Equal("", session.AssumeRole)
+	assert.False(session.MFASession)
+	assert.Equal(opaws.DEFAULT_MFA_SESSION_DURATION, session.Duration, "The MFA session should not use the duration of the role")
+	assert.Equal("arn:aws:iam::222222222222:role/Admin", profile.AssumeRole, "The profile should not be changed")
+	assert.Equal(time.Hour, profile.Duration)
+
+	profile.MFASessionDuration = 8 * time.Hour
+	assert.Equal(8*time.Hour, profile.GetMFASessionProfile().Duration)
+}
@@ -0,0 +1,150 @@
+package opaws
+
+import (
+	"errors"
+	"fmt"
+	"nextunit/op2aws/redact"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// A new access key takes a few seconds until it can be used.
+var (
+	ROTATE_VERIFY_ATTEMPTS = 10
+	ROTATE_VERIFY_INTERVAL = 3 * time.Second
+)
+
+type rollback []func() error
+
+func (r *rollback) add(undo func() error) {
+	*r = append(*r, undo)
+}
+
+// run undoes the steps in reverse order and adds the errors of the rollback to the error of the rotation.
+func (r rollback) run(err error) error {
+	rollbackErrors := []error{}
+	for i := len(r) - 1; i >= 0; i-- {
+		if rollbackErr := r[i](); rollbackErr != nil {
+			rollbackErrors = append(rollbackErrors, rollbackErr)
+		}
+	}
+
+	if len(rollbackErrors) != 0 {
+		return fmt.Errorf("%w. The rollback failed, check the access keys of the user and the item manually: %w", err, errors.Join(rollbackErrors...))
+	}
+
+	return fmt.Errorf("%w. The rotation has been rolled back", err)
+}
+
+func (client OpAWS) generateIamClient(accessKeyId, secretAccessKey string) iamiface.IAMAPI {
+	return client.awsClient.NewIam(client.awsClient.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(accessKeyId, secretAccessKey, ""),
+	}))
+}
+
+func (client OpAWS) verifyAccessKey(accessKey *iam.AccessKey) error {
+	var err error
+	for i := 0; i < ROTATE_VERIFY_ATTEMPTS; i++ {
+		time.Sleep(ROTATE_VERIFY_INTERVAL)
+
+		_, err = client.GetCallerIdentity(&sts.Credentials{
+			AccessKeyId:     accessKey.AccessKeyId,
+			SecretAccessKey: accessKey.SecretAccessKey,
+		})
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The new access key could not be verified: %w", err)
+}
+
+// RotateAccessKey replaces the access key inside of the vault with a new one and returns its id. When a step fails,
+// the previous steps are rolled back.
+func (client OpAWS) RotateAccessKey() (string, error) {
+	oldAccessKeyId, oldSecretAccessKey, err := client.getVaultAccessKey()
+	if err != nil {
+		return "", err
+	}
+
+	oldIamClient := client.generateIamClient(oldAccessKeyId, oldSecretAccessKey)
+	output, err := oldIamClient.CreateAccessKey(&iam.CreateAccessKeyInput{})
+	if err != nil {
+		return "", fmt.Errorf("Unable to create a new access key: %w", err)
+	}
+	accessKey := output.AccessKey
+	redact.Add(aws.StringValue(accessKey.SecretAccessKey))
+
+	steps := rollback{}
+	steps.add(func() error {
+		_, err := oldIamClient.DeleteAccessKey(&iam.DeleteAccessKeyInput{AccessKeyId: accessKey.AccessKeyId})
+		return err
+	})
+
+	// The item might have been changed partly.
+	steps.add(func() error { return client.opClient.SetAccessKeyId(oldAccessKeyId) })
+	if err := client.opClient.SetAccessKeyId(*accessKey.AccessKeyId); err != nil {
+		return "", steps.run(newStageError(STAGE_VAULT, err))
+	}
+
+	steps.add(func() error { return client.opClient.SetSecretAccessKey(oldSecretAccessKey) })
+	if err := client.opClient.SetSecretAccessKey(*accessKey.SecretAccessKey); err != nil {
+		return "", steps.run(newStageError(STAGE_VAULT, err))
+	}
+
+	if err := client.verifyAccessKey(accessKey); err != nil {
+		return "", steps.run(err)
+	}
+
+	newIamClient := client.generateIamClient(*accessKey.AccessKeyId, *accessKey.SecretAccessKey)
+	_, err = newIamClient.UpdateAccessKey(&iam.UpdateAccessKeyInput{AccessKeyId: &oldAccessKeyId, Status: aws.String(iam.StatusTypeInactive)})
+	if err != nil {
+		return "", steps.run(fmt.Errorf("Unable to deactivate the old access key: %w", err))
+	}
+	steps.add(func() error {
+		_, err := newIamClient.UpdateAccessKey(&iam.UpdateAccessKeyInput{AccessKeyId: &oldAccessKeyId, Status: aws.String(iam.StatusTypeActive)})
+		return err
+	})
+
+	_, err = newIamClient.DeleteAccessKey(&iam.DeleteAccessKeyInput{AccessKeyId: &oldAccessKeyId})
+	if err != nil {
+		return "", steps.run(fmt.Errorf("Unable to delete the old access key: %w", err))
+	}
+
+	return *accessKey.AccessKeyId, nil
+}
+
+func getAccessKeyMetadata(iamClient iamiface.IAMAPI, accessKeyId string) (*iam.AccessKeyMetadata, error) {
+	output, err := iamClient.ListAccessKeys(&iam.ListAccessKeysInput{})
+	if err != nil {
+		return nil, newStageError(STAGE_KEY_AGE, err)
+	}
+
+	for _, metadata := range output.AccessKeyMetadata {
+		if aws.StringValue(metadata.AccessKeyId) == accessKeyId && metadata.CreateDate != nil {
+			return metadata, nil
+		}
+	}
+
+	return nil, newStageError(STAGE_KEY_AGE, fmt.Errorf("The access key %s has not been found", accessKeyId))
+}
+
+// GetAccessKeyAge returns how long ago the access key inside of the vault has been created.
+func (client OpAWS) GetAccessKeyAge() (time.Duration, error) {
+	accessKeyId, secretAccessKey, err := client.getVaultAccessKey()
+	if err != nil {
+		return 0, err
+	}
+
+	metadata, err := getAccessKeyMetadata(client.generateIamClient(accessKeyId, secretAccessKey), accessKeyId)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Since(*metadata.CreateDate), nil
+}
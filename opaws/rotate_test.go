@@ -0,0 +1,240 @@
+package opaws_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/opaws"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	setAccessKeyIdReturnValue     error
+	setSecretAccessKeyReturnValue error
+	createAccessKeyReturnValue    *iam.CreateAccessKeyOutput
+	updateAccessKeyReturnValue    error
+	deleteAccessKeyReturnValue    error
+	listAccessKeysReturnValue     *iam.ListAccessKeysOutput
+
+	createAccessKeyCallCount int
+
+	setAccessKeyIdInput     []string
+	setSecretAccessKeyInput []string
+	updateAccessKeyInput    []*iam.UpdateAccessKeyInput
+	deleteAccessKeyInput    []string
+)
+
+type iamApiTest struct {
+	iamiface.IAMAPI
+}
+
+func setupRotateTestCase() {
+	setupTestCase()
+	opaws.ROTATE_VERIFY_INTERVAL = 0
+
+	setAccessKeyIdReturnValue = nil
+	setSecretAccessKeyReturnValue = nil
+	createAccessKeyReturnValue = &iam.CreateAccessKeyOutput{
+		AccessKey: &iam.AccessKey{
+			AccessKeyId:     aws.String("new-access-key-id"),
+			SecretAccessKey: aws.String("new-secret-access-key"),
+		},
+	}
+	updateAccessKeyReturnValue = nil
+	deleteAccessKeyReturnValue = nil
+	listAccessKeysReturnValue = &iam.ListAccessKeysOutput{
+		AccessKeyMetadata: []*iam.AccessKeyMetadata{
+			{AccessKeyId: aws.String("other-access-key-id"), CreateDate: aws.Time(time.Now())},
+			{AccessKeyId: aws.String("access-key-id-default"), CreateDate: aws.Time(time.Now().Add(-48 * time.Hour))},
+		},
+	}
+
+	createAccessKeyCallCount = 0
+
+	setAccessKeyIdInput = []string{}
+	setSecretAccessKeyInput = []string{}
+	updateAccessKeyInput = []*iam.UpdateAccessKeyInput{}
+	deleteAccessKeyInput = []string{}
+}
+
+func (awsVaultTest) SetAccessKeyId(accessKeyId string) error {
+	setAccessKeyIdInput = append(setAccessKeyIdInput, accessKeyId)
+	return setAccessKeyIdReturnValue
+}
+
+func (awsVaultTest) SetSecretAccessKey(secretAccessKey string) error {
+	setSecretAccessKeyInput = append(setSecretAccessKeyInput, secretAccessKey)
+	return setSecretAccessKeyReturnValue
+}
+
+func (opAwsInputTest) NewIam(p client.ConfigProvider, cfgs ...*aws.Config) iamiface.IAMAPI {
+	return &iamApiTest{}
+}
+
+func (iamApiTest) CreateAccessKey(input *iam.CreateAccessKeyInput) (*iam.CreateAccessKeyOutput, error) {
+	createAccessKeyCallCount++
+	if createAccessKeyReturnValue == nil {
+		return nil, fmt.Errorf("Test error CreateAccessKey")
+	}
+	return createAccessKeyReturnValue, nil
+}
+
+func (iamApiTest) UpdateAccessKey(input *iam.UpdateAccessKeyInput) (*iam.UpdateAccessKeyOutput, error) {
+	updateAccessKeyInput = append(updateAccessKeyInput, input)
+	if updateAccessKeyReturnValue != nil && *input.Status == iam.StatusTypeInactive {
+		return nil, updateAccessKeyReturnValue
+	}
+	return &iam.UpdateAccessKeyOutput{}, nil
+}
+
+func (iamApiTest) DeleteAccessKey(input *iam.DeleteAccessKeyInput) (*iam.DeleteAccessKeyOutput, error) {
+	deleteAccessKeyInput = append(deleteAccessKeyInput, *input.AccessKeyId)
+	if deleteAccessKeyReturnValue != nil && *input.AccessKeyId == "access-key-id-default" {
+		return nil, deleteAccessKeyReturnValue
+	}
+	return &iam.DeleteAccessKeyOutput{}, nil
+}
+
+func (iamApiTest) ListAccessKeys(input *iam.ListAccessKeysInput) (*iam.ListAccessKeysOutput, error) {
+	if listAccessKeysReturnValue == nil {
+		return nil, fmt.Errorf("Test error ListAccessKeys")
+	}
+	return listAccessKeysReturnValue, nil
+}
+
+func TestRotateAccessKey(t *testing.T) {
+	setupRotateTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	accessKeyId, err := client.RotateAccessKey()
+
+	assert.Nil(err)
+	assert.Equal("new-access-key-id", accessKeyId)
+	assert.Equal(1, createAccessKeyCallCount)
+	assert.Equal([]string{"new-access-key-id"}, setAccessKeyIdInput)
+	assert.Equal([]string{"new-secret-access-key"}, setSecretAccessKeyInput)
+	assert.Equal(1, getCallerIdentityCallCount, "The new access key should be verified")
+	assert.Len(updateAccessKeyInput, 1)
+	assert.Equal("access-key-id-default", *updateAccessKeyInput[0].AccessKeyId)
+	assert.Equal(iam.StatusTypeInactive, *updateAccessKeyInput[0].Status)
+	assert.Equal([]string{"access-key-id-default"}, deleteAccessKeyInput)
+}
+
+func TestRotateAccessKeyErrors(t *testing.T) {
+	testCasesRotate := []struct {
+		name                            string
+		setup                           func()
+		expectedError                   string
+		expectedSetAccessKeyIdInput     []string
+		expectedSetSecretAccessKeyInput []string
+		expectedDeleteAccessKeyInput    []string
+		expectedUpdateAccessKeyCalls    int
+	}{
+		{
+			name:                            "vault",
+			setup:                           func() { getSecretAccessKeyReturnValue = nil },
+			expectedError:                   "Test error",
+			expectedSetAccessKeyIdInput:     []string{},
+			expectedSetSecretAccessKeyInput: []string{},
+			expectedDeleteAccessKeyInput:    []string{},
+		},
+		{
+			name:                            "create access key",
+			setup:                           func() { createAccessKeyReturnValue = nil },
+			expectedError:                   "Test error CreateAccessKey",
+			expectedSetAccessKeyIdInput:     []string{},
+			expectedSetSecretAccessKeyInput: []string{},
+			expectedDeleteAccessKeyInput:    []string{},
+		},
+		{
+			name:                            "write access key id",
+			setup:                           func() { setAccessKeyIdReturnValue = fmt.Errorf("Test error SetAccessKeyId") },
+			expectedError:                   "Test error SetAccessKeyId",
+			expectedSetAccessKeyIdInput:     []string{"new-access-key-id", "access-key-id-default"},
+			expectedSetSecretAccessKeyInput: []string{},
+			expectedDeleteAccessKeyInput:    []string{"new-access-key-id"},
+		},
+		{
+			name:                            "verify",
+			setup:                           func() { stsErrorReturnValue = fmt.Errorf("Test error GetCallerIdentity") },
+			expectedError:                   "Test error GetCallerIdentity",
+			expectedSetAccessKeyIdInput:     []string{"new-access-key-id", "access-key-id-default"},
+			expectedSetSecretAccessKeyInput: []string{"new-secret-access-key", "secret-access-key-default"},
+			expectedDeleteAccessKeyInput:    []string{"new-access-key-id"},
+		},
+		{
+			name:                            "deactivate",
+			setup:                           func() { updateAccessKeyReturnValue = fmt.Errorf("Test error UpdateAccessKey") },
+			expectedError:                   "Test error UpdateAccessKey",
+			expectedSetAccessKeyIdInput:     []string{"new-access-key-id", "access-key-id-default"},
+			expectedSetSecretAccessKeyInput: []string{"new-secret-access-key", "secret-access-key-default"},
+			expectedDeleteAccessKeyInput:    []string{"new-access-key-id"},
+			expectedUpdateAccessKeyCalls:    1,
+		},
+		{
+			name:                            "delete",
+			setup:                           func() { deleteAccessKeyReturnValue = fmt.Errorf("Test error DeleteAccessKey") },
+			expectedError:                   "Test error DeleteAccessKey",
+			expectedSetAccessKeyIdInput:     []string{"new-access-key-id", "access-key-id-default"},
+			expectedSetSecretAccessKeyInput: []string{"new-secret-access-key", "secret-access-key-default"},
+			expectedDeleteAccessKeyInput:    []string{"access-key-id-default", "new-access-key-id"},
+			expectedUpdateAccessKeyCalls:    2,
+		},
+	}
+
+	for _, v := range testCasesRotate {
+		t.Run(v.name, func(t *testing.T) {
+			setupRotateTestCase()
+			opaws.ROTATE_VERIFY_ATTEMPTS = 2
+			defer func() { opaws.ROTATE_VERIFY_ATTEMPTS = 10 }()
+			v.setup()
+
+			client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+			_, err := client.RotateAccessKey()
+
+			assert.ErrorContains(t, err, v.expectedError)
+			assert.Equal(t, v.expectedSetAccessKeyIdInput, setAccessKeyIdInput)
+			assert.Equal(t, v.expectedSetSecretAccessKeyInput, setSecretAccessKeyInput)
+			assert.Equal(t, v.expectedDeleteAccessKeyInput, deleteAccessKeyInput)
+			assert.Len(t, updateAccessKeyInput, v.expectedUpdateAccessKeyCalls)
+		})
+	}
+}
+
+func TestRotateAccessKeyRollbackError(t *testing.T) {
+	setupRotateTestCase()
+	setSecretAccessKeyReturnValue = fmt.Errorf("Test error SetSecretAccessKey")
+	setAccessKeyIdReturnValue = nil
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	_, err := client.RotateAccessKey()
+
+	assert.ErrorContains(t, err, "Test error SetSecretAccessKey")
+	assert.ErrorContains(t, err, "The rollback failed")
+}
+
+func TestGetAccessKeyAge(t *testing.T) {
+	setupRotateTestCase()
+	assert := assert.New(t)
+
+	client := opaws.New(&awsVaultTest{}, &opAwsInputTest{})
+	age, err := client.GetAccessKeyAge()
+
+	assert.Nil(err)
+	assert.InDelta(48*time.Hour, age, float64(time.Minute))
+
+	getAccessKeyIdReturnValue = aws.String("unknown-access-key-id")
+	_, err = client.GetAccessKeyAge()
+	assert.ErrorContains(err, "has not been found")
+	assert.Equal(opaws.STAGE_KEY_AGE, opaws.GetStage(err))
+
+	listAccessKeysReturnValue = nil
+	_, err = client.GetAccessKeyAge()
+	assert.ErrorContains(err, "Test error ListAccessKeys")
+}
@@ -0,0 +1,98 @@
+package redact
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	REDACTED = "[REDACTED]"
+
+	// OTP_TTL covers the period of a TOTP code and the clock skew, that AWS accepts.
+	OTP_TTL = 2 * time.Minute
+)
+
+var (
+	mutex sync.Mutex
+	// The secrets with their expiration. A zero time never expires.
+	secrets = map[string]time.Time{}
+)
+
+func add(expiration time.Time, values []string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			secrets[value] = expiration
+		}
+	}
+}
+
+// Add registers secrets, that are removed from every output.
+func Add(values ...string) {
+	add(time.Time{}, values)
+}
+
+// AddTemporary registers short-lived secrets like MFA codes, that are forgotten after the ttl.
+func AddTemporary(ttl time.Duration, values ...string) {
+	add(time.Now().Add(ttl), values)
+}
+
+// Reset forgets all registered secrets.
+func Reset() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	secrets = map[string]time.Time{}
+}
+
+// String replaces every registered secret inside of the text.
+func String(text string) string {
+	now := time.Now()
+	mutex.Lock()
+	values := make([]string, 0, len(secrets))
+	for value, expiration := range secrets {
+		if !expiration.IsZero() && expiration.Before(now) {
+			delete(secrets, value)
+			continue
+		}
+		values = append(values, value)
+	}
+	mutex.Unlock()
+
+	// Longer secrets first, so a secret containing another one is replaced completely.
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, REDACTED)
+	}
+
+	return text
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return String(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Error returns an error, whose message doesn't contain the registered secrets, and keeps the wrapped errors.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &redactedError{err: err}
+}
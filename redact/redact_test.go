@@ -0,0 +1,50 @@
+package redact_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"nextunit/op2aws/redact"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	assert := assert.New(t)
+	redact.Reset()
+
+	redact.Add("test-secret", "test-secret-long", " ", "")
+
+	assert.Equal("a [REDACTED] and [REDACTED] b", redact.String("a test-secret and test-secret-long b"))
+	assert.Equal("nothing to hide", redact.String("nothing to hide"))
+
+	redact.Reset()
+	assert.Equal("test-secret", redact.String("test-secret"))
+}
+
+func TestError(t *testing.T) {
+	assert := assert.New(t)
+	redact.Reset()
+	redact.Add("test-secret")
+
+	err := redact.Error(fmt.Errorf("Unable to use test-secret: %w", fs.ErrNotExist))
+
+	assert.Equal("Unable to use [REDACTED]: file does not exist", err.Error())
+	assert.True(errors.Is(err, fs.ErrNotExist), "The wrapped error should still be found")
+	assert.Nil(redact.Error(nil))
+}
+
+func TestAddTemporary(t *testing.T) {
+	assert := assert.New(t)
+	redact.Reset()
+
+	redact.AddTemporary(50*time.Millisecond, "123456")
+	redact.Add("test-secret")
+	assert.Equal("code [REDACTED]", redact.String("code 123456"))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal("arn:aws:iam::111123456789:role/Admin [REDACTED]", redact.String("arn:aws:iam::111123456789:role/Admin test-secret"),
+		"An expired MFA code should not mask an account id anymore")
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	ECS_CREDENTIALS_PATH     = "/credentials"
+	IMDS_TOKEN_PATH          = "/latest/api/token"
+	IMDS_CREDENTIALS_PATH    = "/latest/meta-data/iam/security-credentials/"
+	IMDS_TOKEN_HEADER        = "X-aws-ec2-metadata-token"
+	IMDS_TOKEN_TTL_HEADER    = "X-aws-ec2-metadata-token-ttl-seconds"
+	IMDS_TOKEN_TTL_MAXIMUM   = 21600
+	IMDS_ADDRESS             = "169.254.169.254"
+	DEFAULT_REFRESH_WINDOW   = 5 * time.Minute
+	DEFAULT_REFRESH_INTERVAL = time.Minute
+)
+
+// CredentialsProvider returns the credentials that are served. With force the cache is not used.
+type CredentialsProvider func(force bool) (*sts.Credentials, error)
+
+type CredentialsServer struct {
+	provider      CredentialsProvider
+	token         string
+	roleName      string
+	imds          bool
+	refreshWindow time.Duration
+
+	mutex       sync.Mutex
+	credentials *sts.Credentials
+	imdsTokens  map[string]time.Time
+}
+
+type ecsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+type imdsCredentials struct {
+	Code        string
+	LastUpdated string
+	Type        string
+	ecsCredentials
+}
+
+func GenerateToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isImdsHost returns whether the Host header is a loopback address or the address of the metadata service.
+func isImdsHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && (ip.IsLoopback() || ip.String() == IMDS_ADDRESS)
+}
+
+// imdsOnly rejects requests like IMDSv2: with a Host, that is no address, or with X-Forwarded-For.
+func imdsOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isImdsHost(r.Host) || r.Header.Get("X-Forwarded-For") != "" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func (s *CredentialsServer) needsRefresh() bool {
+	return s.credentials == nil ||
+		s.credentials.Expiration == nil ||
+		time.Until(*s.credentials.Expiration) < s.refreshWindow
+}
+
+// GetCredentials returns the served credentials and refreshes them, when they expire soon.
+func (s *CredentialsServer) GetCredentials() (*sts.Credentials, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.needsRefresh() {
+		return s.credentials, nil
+	}
+
+	credentials, err := s.provider(false)
+	if err != nil {
+		return nil, err
+	}
+	s.credentials = credentials
+
+	if s.needsRefresh() {
+		credentials, err = s.provider(true)
+		if err != nil {
+			return nil, err
+		}
+		s.credentials = credentials
+	}
+
+	return s.credentials, nil
+}
+
+// RefreshLoop refreshes the credentials in the background until done is closed.
+func (s *CredentialsServer) RefreshLoop(interval time.Duration, done <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, err := s.GetCredentials(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func toEcsCredentials(credentials *sts.Credentials) ecsCredentials {
+	c := ecsCredentials{
+		AccessKeyId:     *credentials.AccessKeyId,
+		SecretAccessKey: *credentials.SecretAccessKey,
+	}
+
+	if credentials.SessionToken != nil {
+		c.Token = *credentials.SessionToken
+	}
+
+	if credentials.Expiration != nil {
+		c.Expiration = credentials.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *CredentialsServer) handleEcs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentials, err := s.GetCredentials()
+	if err != nil {
+		http.Error(w, "Unable to get credentials", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, toEcsCredentials(credentials))
+}
+
+func (s *CredentialsServer) handleImdsToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ttl, err := strconv.Atoi(r.Header.Get(IMDS_TOKEN_TTL_HEADER))
+	if err != nil || ttl < 1 || ttl > IMDS_TOKEN_TTL_MAXIMUM {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		http.Error(w, "Unable to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	s.putImdsToken(token, time.Now().Add(time.Duration(ttl)*time.Second))
+
+	w.Header().Set(IMDS_TOKEN_TTL_HEADER, strconv.Itoa(ttl))
+	w.Write([]byte(token))
+}
+
+// putImdsToken adds the token and removes the expired ones.
+func (s *CredentialsServer) putImdsToken(token string, expiration time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for t, e := range s.imdsTokens {
+		if e.Before(now) {
+			delete(s.imdsTokens, t)
+		}
+	}
+
+	s.imdsTokens[token] = expiration
+}
+
+func (s *CredentialsServer) validImdsToken(token string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiration, ok := s.imdsTokens[token]
+	if !ok {
+		return false
+	}
+
+	if expiration.Before(time.Now()) {
+		delete(s.imdsTokens, token)
+		return false
+	}
+
+	return true
+}
+
+func (s *CredentialsServer) handleImdsCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.validImdsToken(r.Header.Get(IMDS_TOKEN_HEADER)) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roleName := strings.TrimPrefix(r.URL.Path, IMDS_CREDENTIALS_PATH)
+	if roleName == "" {
+		w.Write([]byte(s.roleName))
+		return
+	}
+
+	if roleName != s.roleName {
+		http.NotFound(w, r)
+		return
+	}
+
+	credentials, err := s.GetCredentials()
+	if err != nil {
+		http.Error(w, "Unable to get credentials", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, imdsCredentials{
+		Code:           "Success",
+		LastUpdated:    time.Now().UTC().Format(time.RFC3339),
+		Type:           "AWS-HMAC",
+		ecsCredentials: toEcsCredentials(credentials),
+	})
+}
+
+// Handler returns the http handler of the server. Only loopback connections are accepted.
+func (s *CredentialsServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ECS_CREDENTIALS_PATH, s.handleEcs)
+
+	if s.imds {
+		mux.HandleFunc(IMDS_TOKEN_PATH, imdsOnly(s.handleImdsToken))
+		mux.HandleFunc(IMDS_CREDENTIALS_PATH, imdsOnly(s.handleImdsCredentials))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(r.RemoteAddr) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *CredentialsServer) GetToken() string {
+	return s.token
+}
+
+func (s *CredentialsServer) GetRoleName() string {
+	return s.roleName
+}
+
+func (s *CredentialsServer) UseImds(imds bool) {
+	s.imds = imds
+}
+
+func (s *CredentialsServer) RefreshWindow(refreshWindow time.Duration) {
+	s.refreshWindow = refreshWindow
+}
+
+func New(provider CredentialsProvider, token, roleName string) *CredentialsServer {
+	return &CredentialsServer{
+		provider:      provider,
+		token:         token,
+		roleName:      roleName,
+		refreshWindow: DEFAULT_REFRESH_WINDOW,
+		imdsTokens:    map[string]time.Time{},
+	}
+}
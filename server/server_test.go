@@ -0,0 +1,247 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nextunit/op2aws/server"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	providerReturnValues []*sts.Credentials
+
+	providerCallCount int
+
+	providerInput []bool
+)
+
+func setupTestCases() {
+	providerReturnValues = []*sts.Credentials{newCredentials("access-key-id", time.Hour)}
+
+	providerCallCount = 0
+
+	providerInput = []bool{}
+}
+
+func newCredentials(accessKeyId string, validity time.Duration) *sts.Credentials {
+	return &sts.Credentials{
+		AccessKeyId:     aws.String(accessKeyId),
+		SecretAccessKey: aws.String("secret-access-key"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      aws.Time(time.Now().Add(validity)),
+	}
+}
+
+func provider(force bool) (*sts.Credentials, error) {
+	providerInput = append(providerInput, force)
+	providerCallCount++
+
+	if len(providerReturnValues) == 0 || providerReturnValues[0] == nil {
+		return nil, fmt.Errorf("Test error")
+	}
+
+	credentials := providerReturnValues[0]
+	if len(providerReturnValues) > 1 {
+		providerReturnValues = providerReturnValues[1:]
+	}
+
+	return credentials, nil
+}
+
+func request(t *testing.T, method, url string, headers map[string]string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+
+	for k, v := range headers {
+		if k == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+
+	return res.StatusCode, string(body)
+}
+
+func TestEcsCredentials(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, body := request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{"Authorization": "test-token"})
+	assert.Equal(http.StatusOK, status)
+
+	var credentials map[string]string
+	assert.Nil(json.Unmarshal([]byte(body), &credentials))
+	assert.Equal("access-key-id", credentials["AccessKeyId"])
+	assert.Equal("secret-access-key", credentials["SecretAccessKey"])
+	assert.Equal("session-token", credentials["Token"])
+	assert.NotEmpty(credentials["Expiration"])
+
+	request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{"Authorization": "test-token"})
+	assert.Equal(1, providerCallCount, "The credentials should only be requested once while they are valid")
+}
+
+func TestEcsCredentialsUnauthorized(t *testing.T) {
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, _ := request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{"Authorization": "wrong-token"})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{"Authorization": "test-token-with-suffix"})
+	assert.Equal(t, http.StatusUnauthorized, status, "A token with the right prefix should not be accepted")
+
+	status, _ = request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, 0, providerCallCount)
+}
+
+func TestEcsCredentialsProviderError(t *testing.T) {
+	setupTestCases()
+	providerReturnValues = nil
+
+	s := server.New(provider, "test-token", "test-role")
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, body := request(t, http.MethodGet, ts.URL+server.ECS_CREDENTIALS_PATH, map[string]string{"Authorization": "test-token"})
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.NotContains(t, body, "Test error")
+}
+
+func TestNonLoopbackRequest(t *testing.T) {
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	req := httptest.NewRequest(http.MethodGet, server.ECS_CREDENTIALS_PATH, nil)
+	req.RemoteAddr = "192.168.0.10:50000"
+	req.Header.Set("Authorization", "test-token")
+	rec := httptest.NewRecorder()
+
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, 0, providerCallCount)
+}
+
+func TestRefreshBeforeExpiration(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+	providerReturnValues = []*sts.Credentials{
+		newCredentials("expiring-access-key-id", time.Minute),
+		newCredentials("expiring-access-key-id", time.Minute),
+		newCredentials("new-access-key-id", time.Hour),
+	}
+
+	s := server.New(provider, "test-token", "test-role")
+
+	credentials, err := s.GetCredentials()
+	assert.Nil(err)
+	assert.Equal("expiring-access-key-id", *credentials.AccessKeyId)
+	assert.Equal([]bool{false, true}, providerInput, "The cache should be bypassed, when it only contains expiring credentials")
+
+	credentials, err = s.GetCredentials()
+	assert.Nil(err)
+	assert.Equal("new-access-key-id", *credentials.AccessKeyId)
+	assert.Equal([]bool{false, true, false}, providerInput)
+
+	credentials, err = s.GetCredentials()
+	assert.Nil(err)
+	assert.Equal("new-access-key-id", *credentials.AccessKeyId)
+	assert.Equal(3, providerCallCount)
+}
+
+func TestImdsCredentials(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	s.UseImds(true)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, _ := request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH, nil)
+	assert.Equal(http.StatusUnauthorized, status, "IMDSv2 requires a session token")
+
+	status, _ = request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, nil)
+	assert.Equal(http.StatusBadRequest, status, "IMDSv2 requires a ttl for the session token")
+
+	status, token := request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, map[string]string{server.IMDS_TOKEN_TTL_HEADER: "60"})
+	assert.Equal(http.StatusOK, status)
+
+	status, roleName := request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH, map[string]string{server.IMDS_TOKEN_HEADER: token})
+	assert.Equal(http.StatusOK, status)
+	assert.Equal("test-role", roleName)
+
+	status, _ = request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH+"other-role", map[string]string{server.IMDS_TOKEN_HEADER: token})
+	assert.Equal(http.StatusNotFound, status)
+
+	status, body := request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH+roleName, map[string]string{server.IMDS_TOKEN_HEADER: token})
+	assert.Equal(http.StatusOK, status)
+
+	var credentials map[string]string
+	assert.Nil(json.Unmarshal([]byte(body), &credentials))
+	assert.Equal("Success", credentials["Code"])
+	assert.Equal("AWS-HMAC", credentials["Type"])
+	assert.Equal("access-key-id", credentials["AccessKeyId"])
+	assert.Equal("session-token", credentials["Token"])
+}
+
+func TestImdsForbidden(t *testing.T) {
+	assert := assert.New(t)
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	s.UseImds(true)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, _ := request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, map[string]string{server.IMDS_TOKEN_TTL_HEADER: "60", "Host": "attacker.example.com"})
+	assert.Equal(http.StatusForbidden, status, "A DNS name as Host could be DNS rebinding")
+
+	status, _ = request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, map[string]string{server.IMDS_TOKEN_TTL_HEADER: "60", "X-Forwarded-For": "10.0.0.1"})
+	assert.Equal(http.StatusForbidden, status, "Forwarded requests should be rejected")
+
+	status, token := request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, map[string]string{server.IMDS_TOKEN_TTL_HEADER: "60", "Host": server.IMDS_ADDRESS})
+	assert.Equal(http.StatusOK, status)
+
+	status, _ = request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH, map[string]string{server.IMDS_TOKEN_HEADER: token, "Host": "localhost"})
+	assert.Equal(http.StatusForbidden, status)
+
+	status, _ = request(t, http.MethodGet, ts.URL+server.IMDS_CREDENTIALS_PATH, map[string]string{server.IMDS_TOKEN_HEADER: token, "X-Forwarded-For": "127.0.0.1"})
+	assert.Equal(http.StatusForbidden, status)
+	assert.Equal(0, providerCallCount)
+}
+
+func TestImdsDisabled(t *testing.T) {
+	setupTestCases()
+
+	s := server.New(provider, "test-token", "test-role")
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	status, _ := request(t, http.MethodPut, ts.URL+server.IMDS_TOKEN_PATH, map[string]string{server.IMDS_TOKEN_TTL_HEADER: "60"})
+	assert.Equal(t, http.StatusNotFound, status)
+}
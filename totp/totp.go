@@ -0,0 +1,57 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	PERIOD = 30 * time.Second
+	DIGITS = 6
+)
+
+// GenerateCode returns the HOTP code (RFC 4226) of the counter.
+func GenerateCode(key []byte, counter uint64, digits int) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+// DecodeSecret decodes a Base32 secret with spaces, lower case letters or missing padding.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("The secret is no valid Base32 string: %w", err)
+	}
+
+	return key, nil
+}
+
+// Generate returns the TOTP code (RFC 6238) of the Base32 secret at the time.
+func Generate(secret string, t time.Time) (string, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return GenerateCode(key, uint64(t.Unix()/int64(PERIOD.Seconds())), DIGITS), nil
+}
@@ -0,0 +1,54 @@
+package totp_test
+
+import (
+	"fmt"
+	"nextunit/op2aws/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The test vectors of RFC 6238 for SHA1.
+var testCases = []struct {
+	time     int64
+	expected string
+}{
+	{time: 59, expected: "94287082"},
+	{time: 1111111109, expected: "07081804"},
+	{time: 1111111111, expected: "14050471"},
+	{time: 1234567890, expected: "89005924"},
+	{time: 2000000000, expected: "69279037"},
+	{time: 20000000000, expected: "65353130"},
+}
+
+func TestGenerateCode(t *testing.T) {
+	for i, v := range testCases {
+		t.Run(fmt.Sprintf("Run case %d", i), func(t *testing.T) {
+			code := totp.GenerateCode([]byte("12345678901234567890"), uint64(v.time/30), 8)
+
+			assert.Equal(t, v.expected, code)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, secret := range []string{
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+	} {
+		code, err := totp.Generate(secret, time.Unix(1111111109, 0))
+
+		assert.Nil(err)
+		assert.Equal("081804", code, "The last 6 digits of the RFC 6238 test vector are expected")
+	}
+
+	code, err := totp.Generate("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0))
+	assert.Nil(err)
+	assert.Equal("287082", code)
+
+	_, err = totp.Generate("not-base32!", time.Now())
+	assert.ErrorContains(err, "Base32")
+}